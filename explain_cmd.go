@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/render"
+	"github.com/BlackOrder/complete-command/internal/reverse"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// explainResult is the JSON document printed by explain --json.
+type explainResult struct {
+	Action  string         `json:"action"`
+	Tool    string         `json:"tool"`
+	Command string         `json:"command"`
+	Tokens  []render.Token `json:"tokens"`
+	Skipped []string       `json:"skipped,omitempty"`
+}
+
+// runExplain implements "complete-command explain '<cmd>' [flags]". It reads
+// the command back into a registry action like parse does, renders it again
+// and prints every word of it with the field that produced it and what it
+// means. Words the action does not account for are listed at the end.
+func runExplain(args []string, reg *registry.Registry, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	action := fs.String("action", "", "Only consider this action (by ID, title or synonym)")
+	asJSON := fs.Bool("json", false, "Print a JSON document instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s explain '<command>' [--action name] [--json]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	// Allow the command to appear before or after the flags.
+	var line string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		line, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if line == "" {
+		line = strings.Join(fs.Args(), " ")
+	}
+	if strings.TrimSpace(line) == "" {
+		fs.Usage()
+		return 2
+	}
+
+	var ms []reverse.Match
+	if *action != "" {
+		act := reg.Find(*action)
+		if act == nil {
+			fmt.Fprintf(stderr, "Unknown action: %s\n", *action)
+			return 1
+		}
+		ms = reverse.ParseAction(act, line)
+	} else {
+		ms = reverse.Parse(reg, line)
+	}
+	if len(ms) == 0 {
+		fmt.Fprintf(stderr, "No action matches: %s\n", line)
+		return 1
+	}
+	m := ms[0]
+	values := render.Prefill(*m.Action, m.Values)
+	cmd, tokens, err := render.Explain(*m.Action, m.Tool, values, shellquote.FromEnv())
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", m.Action.ID, err)
+		return 1
+	}
+	if *asJSON {
+		writeJSON(stdout, explainResult{Action: m.Action.ID, Tool: m.Tool, Command: cmd, Tokens: tokens, Skipped: m.Skipped})
+		return 0
+	}
+	fmt.Fprintf(stdout, "%s (%s)\n\n", m.Action.Title, m.Tool)
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, tok := range tokens {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", tok.Text, strings.Join(tok.Fields, ","), tok.Help)
+	}
+	tw.Flush()
+	if len(m.Skipped) > 0 {
+		fmt.Fprintf(stdout, "\nNot recognised: %s\n", strings.Join(m.Skipped, " "))
+	}
+	return 0
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/BlackOrder/complete-command/internal/detect"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
 )
 
 // SearchTool identifies a binary used for searching text in files.
@@ -28,6 +29,9 @@ type SearchOptions struct {
 	Glob           string
 	FilesWithMatch bool
 	Hidden         bool
+	// Shell selects how the query, directory and glob are quoted. The zero
+	// value produces POSIX sh quoting.
+	Shell shellquote.Shell
 }
 
 // AvailableSearchTools returns the search tools available on the current host.
@@ -45,8 +49,9 @@ func AvailableSearchTools() []SearchTool {
 func BuildSearchCommand(tool SearchTool, o SearchOptions) string {
 	dir := "."
 	if strings.TrimSpace(o.Dir) != "" {
-		dir = o.Dir
+		dir = shellquote.Quote(o.Shell, o.Dir)
 	}
+	query := shellquote.Quote(o.Shell, o.Query)
 	switch tool {
 	case ToolRG:
 		args := []string{"rg"}
@@ -63,7 +68,7 @@ func BuildSearchCommand(tool SearchTool, o SearchOptions) string {
 			args = append(args, fmt.Sprintf("-C %d", o.Context))
 		}
 		if o.Glob != "" {
-			args = append(args, fmt.Sprintf("-g '%s'", o.Glob))
+			args = append(args, "-g "+shellquote.Quote(o.Shell, o.Glob))
 		}
 		if o.FilesWithMatch {
 			args = append(args, "-l")
@@ -71,7 +76,7 @@ func BuildSearchCommand(tool SearchTool, o SearchOptions) string {
 		if o.Hidden {
 			args = append(args, "-uu")
 		}
-		args = append(args, fmt.Sprintf("'%s'", o.Query), dir)
+		args = append(args, query, dir)
 		return strings.Join(args, " ")
 	case ToolGrep:
 		args := []string{"grep", "-R", "-n"}
@@ -91,10 +96,12 @@ func BuildSearchCommand(tool SearchTool, o SearchOptions) string {
 			args = append(args, "-F")
 		}
 		// grep doesn’t have glob the same way; use dir and pattern.
-		return fmt.Sprintf("%s '%s' %s", strings.Join(args, " "), o.Query, dir)
+		return fmt.Sprintf("%s %s %s", strings.Join(args, " "), query, dir)
 	default:
-		// Fallback: simple awk contains search.
-		q := strings.ReplaceAll(o.Query, "'", "'\\''")
-		return fmt.Sprintf("awk 'FNR==1{fn=FILENAME} /%s/{print fn\":\"FNR\":\"$0}' $(find %s -type f)", q, dir)
+		// Fallback: simple awk search. The pattern is passed through the
+		// environment, so it never becomes part of the awk program text and
+		// escapes such as \. reach the regular expression unchanged, which
+		// awk -v would not allow.
+		return fmt.Sprintf("pat=%s awk 'BEGIN{pat=ENVIRON[\"pat\"]} FNR==1{fn=FILENAME} $0 ~ pat {print fn\":\"FNR\":\"$0}' $(find %s -type f)", query, dir)
 	}
 }
@@ -1,6 +1,9 @@
 package actions
 
 import (
+    "os"
+    "os/exec"
+    "path/filepath"
     "strings"
     "testing"
 )
@@ -33,10 +36,37 @@ func TestBuildSearchCommandRG(t *testing.T) {
 func TestBuildSearchCommandAwk(t *testing.T) {
     opts := SearchOptions{Query: "baz", Dir: "."}
     cmd := BuildSearchCommand(ToolAwk, opts)
-    if !strings.HasPrefix(cmd, "awk") {
+    if !strings.HasPrefix(cmd, "pat=baz awk") {
         t.Fatalf("expected awk fallback, got %s", cmd)
     }
-    if !strings.Contains(cmd, "baz") {
-        t.Errorf("expected query to be present in awk command")
+    if !strings.Contains(cmd, `ENVIRON["pat"]`) {
+        t.Errorf("expected the pattern to be read from the environment")
     }
-}
\ No newline at end of file
+}
+
+func TestBuildSearchCommandAwkKeepsEscapes(t *testing.T) {
+    if _, err := exec.LookPath("awk"); err != nil {
+        t.Skip("awk not installed")
+    }
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "f"), []byte("a.b\naxb\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    opts := SearchOptions{Query: `a\.b`, Dir: dir}
+    out, err := exec.Command("sh", "-c", BuildSearchCommand(ToolAwk, opts)).Output()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := filepath.Join(dir, "f") + ":1:a.b\n"; string(out) != want {
+        t.Fatalf("expected %q, got %q", want, out)
+    }
+}
+
+func TestBuildSearchCommandQuotesInput(t *testing.T) {
+    opts := SearchOptions{Query: "it's $(id)", Dir: "my dir", Glob: "*.go"}
+    cmd := BuildSearchCommand(ToolRG, opts)
+    want := `rg -F -g '*.go' 'it'\''s $(id)' 'my dir'`
+    if cmd != want {
+        t.Fatalf("expected %s, got %s", want, cmd)
+    }
+}
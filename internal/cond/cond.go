@@ -0,0 +1,392 @@
+// Package cond implements the small condition language used by registry
+// fields to decide when they are shown.  A condition compares the selected
+// tool or a field value against a literal, or tests a field for truthiness,
+// and conditions may be combined with and/or, negated and grouped:
+//
+//	tool=rg
+//	method!=GET
+//	literal && tool=rg
+//	!short or (tool=dig and reverse)
+//
+// The special identifier "tool" refers to the currently selected tool.  Any
+// other identifier refers to a field value by key.
+package cond
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Env supplies the data a condition is evaluated against.
+type Env struct {
+	// Tool is the currently selected tool name.
+	Tool string
+	// Values holds field values keyed by field key.  Missing keys are
+	// treated as empty.
+	Values map[string]interface{}
+}
+
+// Expr is a parsed condition.
+type Expr interface {
+	// Eval reports whether the condition holds in the given environment.
+	Eval(env Env) bool
+	// Idents returns the identifiers referenced by the expression.
+	Idents() []string
+	// Comparisons returns the identifier/literal comparisons in the
+	// expression, such as {tool rg} for "tool=rg".
+	Comparisons() []Comparison
+}
+
+// Comparison is an identifier compared against a literal value.
+type Comparison struct {
+	Ident string
+	Value string
+}
+
+// Error describes a syntax error at a byte offset in the source expression.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("condition: %s at offset %d", e.Msg, e.Pos)
+}
+
+// Parse compiles a condition expression.  An empty or blank expression
+// yields an expression that is always true.
+func Parse(src string) (Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return always{}, nil
+	}
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("unexpected %q", p.tok.text)}
+	}
+	return e, nil
+}
+
+// MustParse is like Parse but panics on error.  It is intended for tests and
+// package-level expressions.
+func MustParse(src string) Expr {
+	e, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Eval parses and evaluates src in one step.
+func Eval(src string, env Env) (bool, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return false, err
+	}
+	return e.Eval(env), nil
+}
+
+// Truthy reports whether a field value counts as set: true booleans,
+// non-blank strings, non-zero numbers and non-empty lists.
+func Truthy(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return vv
+	case string:
+		return strings.TrimSpace(vv) != ""
+	case int:
+		return vv != 0
+	case int64:
+		return vv != 0
+	case float64:
+		return vv != 0
+	case []string:
+		return len(vv) > 0
+	default:
+		return true
+	}
+}
+
+// String renders a field value the way comparisons see it.
+func String(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case bool:
+		return strconv.FormatBool(vv)
+	case int:
+		return strconv.Itoa(vv)
+	case int64:
+		return strconv.FormatInt(vv, 10)
+	case float64:
+		return strconv.FormatFloat(vv, 'g', -1, 64)
+	case []string:
+		return strings.Join(vv, ",")
+	default:
+		return fmt.Sprint(vv)
+	}
+}
+
+// --- AST ---
+
+type always struct{}
+
+func (always) Eval(Env) bool             { return true }
+func (always) Idents() []string          { return nil }
+func (always) Comparisons() []Comparison { return nil }
+
+// ident tests the truthiness of a field (or the tool).
+type ident struct{ name string }
+
+func (i ident) Eval(env Env) bool       { return Truthy(lookup(env, i.name)) }
+func (i ident) Idents() []string        { return []string{i.name} }
+func (ident) Comparisons() []Comparison { return nil }
+
+// compare tests a field (or the tool) against a literal.
+type compare struct {
+	name  string
+	value string
+	neg   bool
+}
+
+func (c compare) Eval(env Env) bool {
+	eq := String(lookup(env, c.name)) == c.value
+	return eq != c.neg
+}
+func (c compare) Idents() []string { return []string{c.name} }
+func (c compare) Comparisons() []Comparison {
+	return []Comparison{{Ident: c.name, Value: c.value}}
+}
+
+type not struct{ x Expr }
+
+func (n not) Eval(env Env) bool         { return !n.x.Eval(env) }
+func (n not) Idents() []string          { return n.x.Idents() }
+func (n not) Comparisons() []Comparison { return n.x.Comparisons() }
+
+type and struct{ l, r Expr }
+
+func (a and) Eval(env Env) bool { return a.l.Eval(env) && a.r.Eval(env) }
+func (a and) Idents() []string  { return append(a.l.Idents(), a.r.Idents()...) }
+func (a and) Comparisons() []Comparison {
+	return append(a.l.Comparisons(), a.r.Comparisons()...)
+}
+
+type or struct{ l, r Expr }
+
+func (o or) Eval(env Env) bool { return o.l.Eval(env) || o.r.Eval(env) }
+func (o or) Idents() []string  { return append(o.l.Idents(), o.r.Idents()...) }
+func (o or) Comparisons() []Comparison {
+	return append(o.l.Comparisons(), o.r.Comparisons()...)
+}
+
+func lookup(env Env, name string) interface{} {
+	if v, ok := env.Values[name]; ok {
+		return v
+	}
+	if name == "tool" {
+		return env.Tool
+	}
+	return nil
+}
+
+// --- parser ---
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() { p.tok = p.lex.next() }
+
+func (p *parser) parseOr() (Expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = or{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = and{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{x}, nil
+	case tokLParen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, &Error{Pos: p.tok.pos, Msg: "expected )"}
+		}
+		p.next()
+		return x, nil
+	case tokWord:
+		name := p.tok.text
+		p.next()
+		if p.tok.kind != tokEq && p.tok.kind != tokNe {
+			return ident{name}, nil
+		}
+		neg := p.tok.kind == tokNe
+		p.next()
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, &Error{Pos: p.tok.pos, Msg: "expected value after comparison"}
+		}
+		val := p.tok.text
+		p.next()
+		return compare{name: name, value: val, neg: neg}, nil
+	case tokEOF:
+		return nil, &Error{Pos: p.tok.pos, Msg: "unexpected end of condition"}
+	default:
+		return nil, &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("unexpected %q", p.tok.text)}
+	}
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokEq
+	tokNe
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+	tokIllegal
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}
+	}
+	rest := l.src[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		return token{kind: tokAnd, text: "&&", pos: start}
+	case strings.HasPrefix(rest, "||"):
+		l.pos += 2
+		return token{kind: tokOr, text: "||", pos: start}
+	case strings.HasPrefix(rest, "!="):
+		l.pos += 2
+		return token{kind: tokNe, text: "!=", pos: start}
+	case strings.HasPrefix(rest, "=="):
+		l.pos += 2
+		return token{kind: tokEq, text: "==", pos: start}
+	}
+	switch c := rest[0]; c {
+	case '=':
+		l.pos++
+		return token{kind: tokEq, text: "=", pos: start}
+	case '!':
+		l.pos++
+		return token{kind: tokNot, text: "!", pos: start}
+	case '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}
+	case ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}
+	case '"', '\'':
+		l.pos++
+		var b strings.Builder
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+			}
+			b.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{kind: tokIllegal, text: "unterminated string", pos: start}
+		}
+		l.pos++
+		return token{kind: tokString, text: b.String(), pos: start}
+	}
+	for l.pos < len(l.src) && isWordByte(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start {
+		l.pos++
+		return token{kind: tokIllegal, text: l.src[start:l.pos], pos: start}
+	}
+	word := l.src[start:l.pos]
+	switch word {
+	case "and":
+		return token{kind: tokAnd, text: word, pos: start}
+	case "or":
+		return token{kind: tokOr, text: word, pos: start}
+	case "not":
+		return token{kind: tokNot, text: word, pos: start}
+	}
+	return token{kind: tokWord, text: word, pos: start}
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isWordByte(c byte) bool {
+	switch c {
+	case '=', '!', '(', ')', '&', '|', '"', '\'':
+		return false
+	}
+	return !isSpace(c)
+}
@@ -0,0 +1,85 @@
+package cond
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	env := Env{
+		Tool: "rg",
+		Values: map[string]interface{}{
+			"method":  "POST",
+			"literal": true,
+			"short":   false,
+			"count":   0,
+			"user":    "",
+			"header":  []string{"A:b"},
+		},
+	}
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"tool=rg", true},
+		{"tool==grep", false},
+		{"tool!=grep", true},
+		{"method!=GET", true},
+		{"method=POST", true},
+		{"method='POST'", true},
+		{"literal", true},
+		{"short", false},
+		{"!short", true},
+		{"not short", true},
+		{"count", false},
+		{"user", false},
+		{"header", true},
+		{"missing", false},
+		{"missing=''", true},
+		{"literal && tool=rg", true},
+		{"literal and tool=grep", false},
+		{"short || tool=rg", true},
+		{"short or method=GET", false},
+		{"!(short or method=GET)", true},
+		{"tool=grep or literal and method=POST", true},
+		{"(tool=grep or literal) and method=GET", false},
+	}
+	for _, c := range cases {
+		got, err := Eval(c.expr, env)
+		if err != nil {
+			t.Errorf("Eval(%q) error: %v", c.expr, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Eval(%q) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{"tool=", "(tool=rg", "and", "tool=rg)", "x = 'open", "a &&"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func TestIdents(t *testing.T) {
+	e := MustParse("tool=rg and (method!=GET or !short)")
+	got := e.Idents()
+	want := []string{"tool", "method", "short"}
+	if len(got) != len(want) {
+		t.Fatalf("Idents() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Idents() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	got := MustParse("tool=rg or (method!='GET' and !short)").Comparisons()
+	want := []Comparison{{"tool", "rg"}, {"method", "GET"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Comparisons() = %v, want %v", got, want)
+	}
+}
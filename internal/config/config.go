@@ -2,18 +2,74 @@ package config
 
 import (
     "encoding/json"
+    "errors"
+    "fmt"
     "os"
     "path/filepath"
+    "strings"
+
+    "github.com/BlackOrder/complete-command/internal/statefile"
+
+    yaml "gopkg.in/yaml.v3"
 )
 
+// Version is the schema version of the configuration written by this
+// program. Older files are migrated when loaded.
+const Version = 1
+
 // Config holds user preferences for command tool selection and other settings.
-// Preferences map an action identifier to the preferred tool name.
+// Preferences map an action identifier to the preferred tool name. Policy
+// decides which dangerous commands need confirmation or are refused.
+// Remembered holds the last values of fields marked remember in the
+// registry, by action identifier and field key. These top-level settings
+// form the base that the named Profiles inherit from; Profile names the
+// one used when no other is selected.
 type Config struct {
-    Preferences map[string]string `json:"preferences"`
+    Version     int                               `json:"version" yaml:"version"`
+    Preferences map[string]string                 `json:"preferences" yaml:"preferences"`
+    Policy      []Rule                            `json:"policy,omitempty" yaml:"policy,omitempty"`
+    Remembered  map[string]map[string]interface{} `json:"remembered,omitempty" yaml:"remembered,omitempty"`
+    Profile     string                            `json:"profile,omitempty" yaml:"profile,omitempty"`
+    Profiles    map[string]*Profile               `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+
+    // active is the profile selected for this session with Use.
+    active string
+}
+
+// migrations upgrade a configuration from the version at their index to
+// the next one.
+var migrations = []func(*Config){
+    // Version 0 is the legacy ~/.complete-command.json, which only held
+    // preferences in the current layout.
+    func(*Config) {},
+}
+
+// newConfig returns an empty configuration of the current version.
+func newConfig() *Config {
+    return &Config{Version: Version, Preferences: make(map[string]string)}
+}
+
+// Path returns the configuration file: config.yaml in
+// $XDG_CONFIG_HOME/complete-command when it exists and config.json there
+// otherwise. XDG_CONFIG_HOME defaults to ~/.config.
+func Path() (string, error) {
+    dir := os.Getenv("XDG_CONFIG_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", err
+        }
+        dir = filepath.Join(home, ".config")
+    }
+    dir = filepath.Join(dir, "complete-command")
+    if p := filepath.Join(dir, "config.yaml"); fileExists(p) {
+        return p, nil
+    }
+    return filepath.Join(dir, "config.json"), nil
 }
 
-// defaultPath returns the path to the configuration file in the user's home directory.
-func defaultPath() (string, error) {
+// legacyPath returns the configuration file used before Path.
+func legacyPath() (string, error) {
     home, err := os.UserHomeDir()
     if err != nil {
         return "", err
@@ -21,53 +77,224 @@ func defaultPath() (string, error) {
     return filepath.Join(home, ".complete-command.json"), nil
 }
 
-// Load reads the configuration from disk. If the file does not exist, it
-// returns a Config with empty preferences.
+// Load reads the configuration from disk, migrating it to the current
+// version. Without a configuration file the legacy one is migrated and
+// written to Path; without either it returns an empty configuration. On
+// errors, such as a file that does not parse, it returns an empty
+// configuration along with an error naming the file.
 func Load() (*Config, error) {
-    path, err := defaultPath()
+    path, err := Path()
     if err != nil {
-        return nil, err
+        return newConfig(), err
+    }
+    cfg, err := read(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return loadLegacy(path)
     }
+    if err != nil {
+        return newConfig(), err
+    }
+    return cfg, nil
+}
+
+// loadLegacy migrates the legacy configuration, if any, to path.
+func loadLegacy(path string) (*Config, error) {
+    legacy, err := legacyPath()
+    if err != nil {
+        return newConfig(), nil
+    }
+    cfg, err := read(legacy)
+    if errors.Is(err, os.ErrNotExist) {
+        return newConfig(), nil
+    }
+    if err != nil {
+        return newConfig(), err
+    }
+    unlock, err := statefile.Lock(path)
+    if err != nil {
+        return cfg, err
+    }
+    defer unlock()
+    // Another session may have migrated the file meanwhile.
+    if !fileExists(path) {
+        err = write(path, cfg)
+    }
+    return cfg, err
+}
+
+// read decodes and migrates the configuration file at path, as YAML or
+// JSON by its extension.
+func read(path string) (*Config, error) {
     data, err := os.ReadFile(path)
     if err != nil {
-        if os.IsNotExist(err) {
-            return &Config{Preferences: make(map[string]string)}, nil
-        }
         return nil, err
     }
-    var cfg Config
-    if err := json.Unmarshal(data, &cfg); err != nil {
-        return nil, err
+    cfg := &Config{}
+    if isYAML(path) {
+        err = yaml.Unmarshal(data, cfg)
+    } else {
+        err = json.Unmarshal(data, cfg)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if cfg.Version > Version {
+        return nil, fmt.Errorf("%s: version %d is newer than this program supports (%d)", path, cfg.Version, Version)
+    }
+    for cfg.Version < Version {
+        migrations[cfg.Version](cfg)
+        cfg.Version++
+    }
+    if err := cfg.checkPolicy(); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
     }
     if cfg.Preferences == nil {
         cfg.Preferences = make(map[string]string)
     }
-    return &cfg, nil
+    return cfg, nil
+}
+
+// write replaces the file at path with cfg atomically.
+func write(path string, cfg *Config) error {
+    cfg.Version = Version
+    var data []byte
+    var err error
+    if isYAML(path) {
+        data, err = yaml.Marshal(cfg)
+    } else {
+        data, err = json.MarshalIndent(cfg, "", "  ")
+    }
+    if err != nil {
+        return err
+    }
+    return statefile.Write(path, data)
 }
 
-// Save writes the configuration to disk. It overwrites any existing file.
+// Save writes the configuration to disk, replacing the whole file. Prefer
+// Update, which keeps changes made by other sessions meanwhile.
 func Save(cfg *Config) error {
-    path, err := defaultPath()
+    path, err := Path()
+    if err != nil {
+        return err
+    }
+    unlock, err := statefile.Lock(path)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    return write(path, cfg)
+}
+
+// Update applies change to cfg and to the configuration on disk, re-read
+// under the lock so that concurrent sessions do not undo each other's
+// changes, then refreshes cfg from the result. A file that fails to load is
+// left alone and its error returned.
+func Update(cfg *Config, change func(*Config)) error {
+    change(cfg)
+    path, err := Path()
     if err != nil {
         return err
     }
-    data, err := json.MarshalIndent(cfg, "", "  ")
+    unlock, err := statefile.Lock(path)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    fresh, err := read(path)
+    if errors.Is(err, os.ErrNotExist) {
+        fresh, err = newConfig(), nil
+    }
     if err != nil {
         return err
     }
-    return os.WriteFile(path, data, 0o644)
+    fresh.active = cfg.active
+    change(fresh)
+    if err := write(path, fresh); err != nil {
+        return err
+    }
+    *cfg = *fresh
+    return nil
 }
 
-// SetPreference records the selected tool for the given action identifier.
+// isYAML reports whether path names a YAML file.
+func isYAML(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    return ext == ".yaml" || ext == ".yml"
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+    _, err := os.Stat(path)
+    return err == nil
+}
+
+// SetPreference records the selected tool for the given action identifier
+// in the active profile.
 func (c *Config) SetPreference(actionID, tool string) {
-    if c.Preferences == nil {
-        c.Preferences = make(map[string]string)
+    prefs := &c.Preferences
+    if p := c.layer(); p != nil {
+        prefs = &p.Preferences
+    }
+    if *prefs == nil {
+        *prefs = make(map[string]string)
     }
-    c.Preferences[actionID] = tool
+    (*prefs)[actionID] = tool
 }
 
-// PreferredTool returns the preferred tool for the given action identifier, if any.
+// PreferredTool returns the preferred tool for the given action identifier, if any,
+// from the active profile or the ones it inherits from.
 func (c *Config) PreferredTool(actionID string) (string, bool) {
+    for _, p := range c.chain() {
+        if t, ok := p.Preferences[actionID]; ok {
+            return t, true
+        }
+    }
     t, ok := c.Preferences[actionID]
     return t, ok
-}
\ No newline at end of file
+}
+
+// Remember records the last value of a field of the given action in the
+// active profile.
+func (c *Config) Remember(actionID, key string, value interface{}) {
+    remembered := &c.Remembered
+    if p := c.layer(); p != nil {
+        remembered = &p.Remembered
+    }
+    if *remembered == nil {
+        *remembered = make(map[string]map[string]interface{})
+    }
+    if (*remembered)[actionID] == nil {
+        (*remembered)[actionID] = make(map[string]interface{})
+    }
+    (*remembered)[actionID][key] = value
+}
+
+// RememberedValues returns the remembered field values of the given action
+// identifier, keyed by field key. Values of the active profile override
+// those it inherits.
+func (c *Config) RememberedValues(actionID string) map[string]interface{} {
+    if c == nil {
+        return nil
+    }
+    values := make(map[string]interface{})
+    for k, v := range c.Remembered[actionID] {
+        values[k] = v
+    }
+    ps := c.chain()
+    for i := len(ps) - 1; i >= 0; i-- {
+        for k, v := range ps[i].Remembered[actionID] {
+            values[k] = v
+        }
+    }
+    return values
+}
+
+// Forget drops the remembered field values of the given action identifier
+// from the active profile. Values it inherits apply again.
+func (c *Config) Forget(actionID string) {
+    if p := c.layer(); p != nil {
+        delete(p.Remembered, actionID)
+        return
+    }
+    delete(c.Remembered, actionID)
+}
@@ -2,7 +2,12 @@ package config
 
 import (
     "os"
+    "path/filepath"
+    "strings"
+    "sync"
     "testing"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
 )
 
 // TestLoadSave ensures that preferences are persisted across load/save cycles.
@@ -14,6 +19,7 @@ func TestLoadSave(t *testing.T) {
     if err := os.Setenv("HOME", tmp); err != nil {
         t.Fatalf("failed to set HOME: %v", err)
     }
+    t.Setenv("XDG_CONFIG_HOME", "")
     cfg, err := Load()
     if err != nil {
         t.Fatalf("Load error: %v", err)
@@ -32,4 +38,232 @@ func TestLoadSave(t *testing.T) {
     if pref, ok := cfg2.PreferredTool("action"); !ok || pref != "tool" {
         t.Fatalf("expected preference 'tool', got %v %v", pref, ok)
     }
-}
\ No newline at end of file
+}
+
+// TestDecide checks that the first matching policy rule wins and that
+// dangerous commands are confirmed by default.
+func TestDecide(t *testing.T) {
+    cfg := &Config{Policy: []Rule{
+        {Action: "user/*", Tool: "gpasswd", Decision: Deny},
+        {Action: "user/*", Decision: Allow},
+        {Level: "high", Decision: "ask"},
+    }}
+    tests := []struct {
+        action, tool string
+        level        registry.Level
+        want         string
+    }{
+        {"user/mod-group", "gpasswd", registry.High, Deny},
+        {"user/add", "useradd", registry.High, Allow},
+        {"net/http", "curl", registry.High, Confirm},
+        {"net/http", "curl", registry.Medium, Confirm},
+        {"net/http", "curl", registry.Low, Allow},
+    }
+    for _, tt := range tests {
+        if got := cfg.Decide(tt.action, tt.tool, tt.level); got != tt.want {
+            t.Errorf("Decide(%s, %s, %v) = %s, want %s", tt.action, tt.tool, tt.level, got, tt.want)
+        }
+    }
+    var none *Config
+    if got := none.Decide("a", "b", registry.None); got != Allow {
+        t.Errorf("nil config decides %s, want allow", got)
+    }
+}
+
+// TestRemember ensures that remembered field values survive a save and can
+// be forgotten.
+func TestRemember(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("XDG_CONFIG_HOME", "")
+    cfg, err := Load()
+    if err != nil {
+        t.Fatalf("Load error: %v", err)
+    }
+    cfg.Remember("ssh/login", "host", "example.com")
+    cfg.Remember("ssh/login", "port", 2222)
+    if err := Save(cfg); err != nil {
+        t.Fatalf("Save error: %v", err)
+    }
+    cfg2, err := Load()
+    if err != nil {
+        t.Fatalf("Load after save error: %v", err)
+    }
+    got := cfg2.RememberedValues("ssh/login")
+    if got["host"] != "example.com" || got["port"] != float64(2222) {
+        t.Fatalf("remembered values = %v", got)
+    }
+    cfg2.Forget("ssh/login")
+    if got := cfg2.RememberedValues("ssh/login"); len(got) != 0 {
+        t.Fatalf("values not forgotten: %v", got)
+    }
+}
+
+// TestMigrateLegacy checks that the legacy file in the home directory is
+// moved to the XDG location with a version.
+func TestMigrateLegacy(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+    legacy := `{"preferences": {"search/files": "grep"}}`
+    if err := os.WriteFile(filepath.Join(home, ".complete-command.json"), []byte(legacy), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    cfg, err := Load()
+    if err != nil {
+        t.Fatalf("Load error: %v", err)
+    }
+    if pref, _ := cfg.PreferredTool("search/files"); pref != "grep" || cfg.Version != Version {
+        t.Fatalf("legacy config not migrated: %+v", cfg)
+    }
+    path := filepath.Join(home, "xdg", "complete-command", "config.json")
+    data, err := os.ReadFile(path)
+    if err != nil || !strings.Contains(string(data), `"version": 1`) {
+        t.Fatalf("migrated config not written: %s, %v", data, err)
+    }
+    if st, _ := os.Stat(path); st.Mode().Perm() != 0o600 {
+        t.Errorf("config mode = %v, want 0600", st.Mode().Perm())
+    }
+}
+
+// TestLoadErrors checks that unreadable configs are reported with their
+// path and never overwritten.
+func TestLoadErrors(t *testing.T) {
+    dir := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", dir)
+    path := filepath.Join(dir, "complete-command", "config.json")
+    os.MkdirAll(filepath.Dir(path), 0o755)
+    for _, data := range []string{
+        `{"preferences": `,
+        `{"version": 99}`,
+        `{"policy": [{"level": "hgih", "decision": "deny"}]}`,
+        `{"profiles": {"work": {"policy": [{"level": "hgih", "decision": "confirm"}]}}}`,
+    } {
+        os.WriteFile(path, []byte(data), 0o600)
+        cfg, err := Load()
+        if err == nil || !strings.Contains(err.Error(), path) || cfg == nil {
+            t.Errorf("Load(%s) = %v, %v; want an error naming the file", data, cfg, err)
+        }
+        if err := Update(cfg, func(c *Config) { c.SetPreference("a", "b") }); err == nil {
+            t.Errorf("Update over %s succeeded", data)
+        }
+        if got, _ := os.ReadFile(path); string(got) != data {
+            t.Errorf("broken config overwritten with %s", got)
+        }
+    }
+}
+
+// TestYAML checks that a config.yaml takes precedence and is written back
+// as YAML.
+func TestYAML(t *testing.T) {
+    dir := t.TempDir()
+    t.Setenv("XDG_CONFIG_HOME", dir)
+    path := filepath.Join(dir, "complete-command", "config.yaml")
+    os.MkdirAll(filepath.Dir(path), 0o755)
+    os.WriteFile(path, []byte("preferences: {net/http: curl}\npolicy: [{action: user/*, decision: deny}]\n"), 0o600)
+    cfg, err := Load()
+    if err != nil {
+        t.Fatalf("Load error: %v", err)
+    }
+    if pref, _ := cfg.PreferredTool("net/http"); pref != "curl" || len(cfg.Policy) != 1 || cfg.Policy[0].Decision != Deny {
+        t.Fatalf("YAML config not read: %+v", cfg)
+    }
+    if err := Update(cfg, func(c *Config) { c.SetPreference("ssh/login", "ssh") }); err != nil {
+        t.Fatal(err)
+    }
+    data, _ := os.ReadFile(path)
+    if !strings.Contains(string(data), "ssh/login: ssh") {
+        t.Errorf("config not written as YAML:\n%s", data)
+    }
+}
+
+// TestUpdateConcurrent checks that sessions updating the config at the same
+// time keep each other's changes.
+func TestUpdateConcurrent(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        cfg, err := Load()
+        if err != nil {
+            t.Fatal(err)
+        }
+        wg.Add(1)
+        go func(i int, cfg *Config) {
+            defer wg.Done()
+            id := string(rune('a' + i))
+            if err := Update(cfg, func(c *Config) { c.SetPreference(id, "tool") }); err != nil {
+                t.Error(err)
+            }
+        }(i, cfg)
+    }
+    wg.Wait()
+    cfg, err := Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(cfg.Preferences) != 8 {
+        t.Errorf("preferences = %v, want 8 entries", cfg.Preferences)
+    }
+}
+
+// TestProfiles checks that profiles inherit unset preferences, remembered
+// values and policy rules and record changes in themselves.
+func TestProfiles(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    cfg := &Config{
+        Preferences: map[string]string{"net/http": "curl", "ssh/login": "ssh"},
+        Policy:      []Rule{{Decision: Allow}},
+        Remembered:  map[string]map[string]interface{}{"ssh/login": {"user": "me", "port": 22}},
+        Profiles: map[string]*Profile{
+            "work": {Preferences: map[string]string{"net/http": "wget"}},
+            "prod-bastion": {
+                Inherits:   "work",
+                Policy:     []Rule{{Action: "user/*", Decision: Deny}},
+                Remembered: map[string]map[string]interface{}{"ssh/login": {"user": "ops"}},
+            },
+            "loop": {Inherits: "loop"},
+        },
+    }
+    if err := Save(cfg); err != nil {
+        t.Fatal(err)
+    }
+    if err := cfg.Use("nope"); err == nil {
+        t.Error("Use(nope) succeeded")
+    }
+    if err := cfg.Use("loop"); err == nil {
+        t.Error("Use(loop) succeeded")
+    }
+    if err := cfg.Use("prod-bastion"); err != nil {
+        t.Fatal(err)
+    }
+    if pref, _ := cfg.PreferredTool("net/http"); pref != "wget" {
+        t.Errorf("net/http preference = %q, want inherited wget", pref)
+    }
+    if pref, _ := cfg.PreferredTool("ssh/login"); pref != "ssh" {
+        t.Errorf("ssh/login preference = %q, want base ssh", pref)
+    }
+    if got := cfg.RememberedValues("ssh/login"); got["user"] != "ops" || got["port"] != 22 {
+        t.Errorf("remembered values = %v", got)
+    }
+    if got := cfg.Decide("user/add", "useradd", registry.High); got != Deny {
+        t.Errorf("Decide = %q, want the profile's deny", got)
+    }
+    if err := Update(cfg, func(c *Config) { c.SetPreference("ssh/login", "mosh") }); err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Active() != "prod-bastion" || cfg.Profiles["prod-bastion"].Preferences["ssh/login"] != "mosh" || cfg.Preferences["ssh/login"] != "ssh" {
+        t.Errorf("preference not recorded in the active profile: %+v", cfg)
+    }
+    cfg.Forget("ssh/login")
+    if got := cfg.RememberedValues("ssh/login"); got["user"] != "me" {
+        t.Errorf("remembered values after Forget = %v, want the base's", got)
+    }
+    if err := cfg.Use(Base); err != nil {
+        t.Fatal(err)
+    }
+    if pref, _ := cfg.PreferredTool("net/http"); pref != "curl" {
+        t.Errorf("base net/http preference = %q", pref)
+    }
+    if got := cfg.ProfileNames(); strings.Join(got, ",") != "base,loop,prod-bastion,work" {
+        t.Errorf("ProfileNames = %v", got)
+    }
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+    "fmt"
+    "path"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
+)
+
+// Decisions a policy rule can make about a dangerous command.
+const (
+    Allow   = "allow"
+    Confirm = "confirm"
+    Deny    = "deny"
+)
+
+// Rule is a policy entry deciding what happens to matching commands before
+// they are inserted or run.
+type Rule struct {
+    // Action is a glob matched against the action ID, such as "user/*".
+    // Empty matches every action.
+    Action string `json:"action,omitempty" yaml:"action,omitempty"`
+    // Tool limits the rule to one tool. Empty matches every tool.
+    Tool string `json:"tool,omitempty" yaml:"tool,omitempty"`
+    // Level is the lowest danger level the rule applies to. Empty applies
+    // to every level.
+    Level    string `json:"level,omitempty" yaml:"level,omitempty"`
+    Decision string `json:"decision" yaml:"decision"`
+}
+
+// matches reports whether the rule applies to a command.
+func (r Rule) matches(actionID, tool string, level registry.Level) bool {
+    if r.Action != "" {
+        if ok, _ := path.Match(r.Action, actionID); !ok {
+            return false
+        }
+    }
+    if r.Tool != "" && r.Tool != tool {
+        return false
+    }
+    min, err := registry.ParseLevel(r.Level)
+    return err == nil && level >= min
+}
+
+// checkPolicy reports the first rule, of the configuration or one of its
+// profiles, whose level is not a danger level. Such a rule would never
+// match, quietly disabling what it confirms or denies.
+func (c *Config) checkPolicy() error {
+    check := func(where string, rules []Rule) error {
+        for i, r := range rules {
+            if _, err := registry.ParseLevel(r.Level); err != nil {
+                return fmt.Errorf("%s rule %d: %w", where, i+1, err)
+            }
+        }
+        return nil
+    }
+    if err := check("policy", c.Policy); err != nil {
+        return err
+    }
+    for _, name := range c.ProfileNames() {
+        if p := c.Profiles[name]; p != nil {
+            if err := check("profile "+name+" policy", p.Policy); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Decide returns Allow, Confirm or Deny for a command of the given action
+// and tool with the given danger level. The first matching rule of the
+// policy decides, the rules of the active profile coming before those it
+// inherits; without one, commands of medium danger or more are confirmed
+// and the rest allowed. A rule with an unknown decision confirms.
+func (c *Config) Decide(actionID, tool string, level registry.Level) string {
+    if c != nil {
+        var rules []Rule
+        for _, p := range c.chain() {
+            rules = append(rules, p.Policy...)
+        }
+        for _, r := range append(rules, c.Policy...) {
+            if !r.matches(actionID, tool, level) {
+                continue
+            }
+            switch r.Decision {
+            case Allow, Deny:
+                return r.Decision
+            }
+            return Confirm
+        }
+    }
+    if level >= registry.Medium {
+        return Confirm
+    }
+    return Allow
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+    "fmt"
+    "sort"
+)
+
+// ProfileEnv names the environment variable selecting the profile when no
+// --profile flag is given.
+const ProfileEnv = "COMPLETE_COMMAND_PROFILE"
+
+// Base names the top level of the configuration when selecting a profile.
+// Every chain of inheritance ends in it.
+const Base = "base"
+
+// Profile holds the settings of a named profile, such as one per machine or
+// environment. Preferences and remembered values it lacks are inherited
+// from the profile named by Inherits, or from the base when that is empty.
+// Its policy rules are consulted before the inherited ones.
+type Profile struct {
+    Inherits    string                            `json:"inherits,omitempty" yaml:"inherits,omitempty"`
+    Preferences map[string]string                 `json:"preferences,omitempty" yaml:"preferences,omitempty"`
+    Policy      []Rule                            `json:"policy,omitempty" yaml:"policy,omitempty"`
+    Remembered  map[string]map[string]interface{} `json:"remembered,omitempty" yaml:"remembered,omitempty"`
+}
+
+// Use makes the named profile the active one for this session. Base or an
+// empty name selects the top level. It fails for unknown profiles and for
+// profiles whose inheritance is broken.
+func (c *Config) Use(name string) error {
+    if name == "" {
+        name = Base
+    }
+    if name != Base {
+        if _, ok := c.Profiles[name]; !ok {
+            return fmt.Errorf("unknown profile %q", name)
+        }
+        if err := c.checkProfile(name); err != nil {
+            return err
+        }
+    }
+    c.active = name
+    return nil
+}
+
+// Active returns the name of the profile in use: the one selected with
+// Use, else the saved Profile, else Base.
+func (c *Config) Active() string {
+    switch {
+    case c == nil:
+        return Base
+    case c.active != "":
+        return c.active
+    case c.Profile != "":
+        return c.Profile
+    }
+    return Base
+}
+
+// ProfileNames returns Base followed by the names of the profiles in
+// alphabetical order.
+func (c *Config) ProfileNames() []string {
+    names := make([]string, 0, len(c.Profiles))
+    for name := range c.Profiles {
+        if name != Base {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    return append([]string{Base}, names...)
+}
+
+// checkProfile reports profiles inheriting from unknown profiles or from
+// themselves on the way from name to the base.
+func (c *Config) checkProfile(name string) error {
+    seen := map[string]bool{}
+    for name != "" && name != Base {
+        if seen[name] {
+            return fmt.Errorf("profile %q inherits from itself", name)
+        }
+        seen[name] = true
+        p, ok := c.Profiles[name]
+        if !ok || p == nil {
+            return fmt.Errorf("unknown profile %q", name)
+        }
+        name = p.Inherits
+    }
+    return nil
+}
+
+// chain returns the active profile followed by the profiles it inherits
+// from, most specific first, leaving out the base. It stops at unknown
+// profiles and cycles.
+func (c *Config) chain() []*Profile {
+    var ps []*Profile
+    seen := map[string]bool{}
+    for name := c.Active(); name != "" && name != Base && !seen[name]; {
+        seen[name] = true
+        p := c.Profiles[name]
+        if p == nil {
+            break
+        }
+        ps = append(ps, p)
+        name = p.Inherits
+    }
+    return ps
+}
+
+// layer returns the profile changes are recorded in: the active one, or
+// nil for the base.
+func (c *Config) layer() *Profile {
+    if ps := c.chain(); len(ps) > 0 {
+        return ps[0]
+    }
+    return nil
+}
@@ -1,9 +1,7 @@
 package detect
 
-import "os/exec"
-
 // Has reports whether the given executable is available on the system's PATH.
+// Lookups are cached until PATH or a directory on it changes.
 func Has(bin string) bool {
-    _, err := exec.LookPath(bin)
-    return err == nil
-}
\ No newline at end of file
+    return lookPath(bin) != ""
+}
@@ -1,6 +1,11 @@
 package detect
 
-import "testing"
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
 
 func TestHas(t *testing.T) {
     // At least one of sh or bash should exist on most systems used for testing.
@@ -11,4 +16,30 @@ func TestHas(t *testing.T) {
     if Has("unlikely_nonexistent_command_name") {
         t.Errorf("expected nonexistent command to be absent")
     }
-}
\ No newline at end of file
+}
+
+// TestAvailable checks that lookups are cached and dropped once a directory
+// on PATH changes.
+func TestAvailable(t *testing.T) {
+    dir := t.TempDir()
+    t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+    bin := filepath.Join(dir, "bin")
+    if err := os.Mkdir(bin, 0o755); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("PATH", bin)
+    old := time.Now().Add(-time.Hour)
+    os.Chtimes(bin, old, old)
+    if got := Available([]string{"tool", "tool"}); len(got) != 1 || got["tool"] {
+        t.Fatalf("Available = %v, want tool missing", got)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "cache", "complete-command", "tools.json")); err != nil {
+        t.Errorf("lookups not saved: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(bin, "tool"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if !Has("tool") {
+        t.Error("newly installed tool not found")
+    }
+}
@@ -0,0 +1,131 @@
+package detect
+
+import (
+    "encoding/json"
+    "fmt"
+    "hash/fnv"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sync"
+)
+
+// lookups caches where binaries are found on PATH, in memory and on disk.
+// The cache is dropped when PATH or any directory on it changes, so
+// installing or removing a tool is noticed.
+var lookups struct {
+    sync.Mutex
+    loaded bool
+    key    string
+    paths  map[string]string
+}
+
+// lookupFile is the on-disk form of the lookup cache.
+type lookupFile struct {
+    Key   string            `json:"key"`
+    Paths map[string]string `json:"paths"`
+}
+
+// lookupCachePath returns the file lookups are kept in.
+func lookupCachePath() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "complete-command", "tools.json"), nil
+}
+
+// pathKey fingerprints PATH and the modification times of its directories.
+func pathKey() string {
+    h := fnv.New64a()
+    path := os.Getenv("PATH")
+    fmt.Fprintln(h, path)
+    for _, dir := range filepath.SplitList(path) {
+        if st, err := os.Stat(dir); err == nil {
+            fmt.Fprintln(h, dir, st.ModTime().UnixNano())
+        }
+    }
+    return fmt.Sprintf("%x", h.Sum64())
+}
+
+// current returns the lookups valid for the current PATH. It must be
+// called with lookups locked.
+func current() map[string]string {
+    key := pathKey()
+    if !lookups.loaded {
+        lookups.loaded = true
+        if p, err := lookupCachePath(); err == nil {
+            if data, err := os.ReadFile(p); err == nil {
+                var f lookupFile
+                if json.Unmarshal(data, &f) == nil && f.Key == key && f.Paths != nil {
+                    lookups.key, lookups.paths = f.Key, f.Paths
+                }
+            }
+        }
+    }
+    if lookups.key != key || lookups.paths == nil {
+        lookups.key, lookups.paths = key, make(map[string]string)
+    }
+    return lookups.paths
+}
+
+// lookPath returns the path of bin on PATH, or "" when it is not there.
+func lookPath(bin string) string {
+    lookups.Lock()
+    p, ok := current()[bin]
+    lookups.Unlock()
+    if ok {
+        return p
+    }
+    p, _ = exec.LookPath(bin)
+    lookups.Lock()
+    current()[bin] = p
+    lookups.Unlock()
+    return p
+}
+
+// Available looks up every one of bins on PATH concurrently and reports
+// which are installed. The results are kept for Has and later runs.
+func Available(bins []string) map[string]bool {
+    // Deduplicate before starting any goroutine; each one then writes only
+    // its own slot of ok.
+    var unique []string
+    seen := make(map[string]bool, len(bins))
+    for _, bin := range bins {
+        if !seen[bin] {
+            seen[bin] = true
+            unique = append(unique, bin)
+        }
+    }
+    ok := make([]bool, len(unique))
+    var wg sync.WaitGroup
+    for i, bin := range unique {
+        wg.Add(1)
+        go func(i int, bin string) {
+            defer wg.Done()
+            ok[i] = lookPath(bin) != ""
+        }(i, bin)
+    }
+    wg.Wait()
+    saveLookups()
+    found := make(map[string]bool, len(unique))
+    for i, bin := range unique {
+        found[bin] = ok[i]
+    }
+    return found
+}
+
+// saveLookups writes the lookup cache to disk.
+func saveLookups() {
+    lookups.Lock()
+    f := lookupFile{Key: lookups.key, Paths: lookups.paths}
+    data, err := json.MarshalIndent(f, "", "  ")
+    lookups.Unlock()
+    if err != nil {
+        return
+    }
+    if p, err := lookupCachePath(); err == nil {
+        _ = os.MkdirAll(filepath.Dir(p), 0o755)
+        _ = os.WriteFile(p, data, 0o644)
+    }
+}
@@ -0,0 +1,257 @@
+package detect
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Info describes what an installed tool is and what it can do, as found by
+// running it with --version and --help.
+type Info struct {
+    Path string `json:"path"`
+    // ModTime and Size identify the binary the info was probed from.
+    ModTime time.Time `json:"modTime"`
+    Size    int64     `json:"size"`
+    // Vendor names the implementation, such as "gnu", "bsd", "busybox" or
+    // "ripgrep", when it can be told.
+    Vendor  string `json:"vendor,omitempty"`
+    Version string `json:"version,omitempty"`
+    // Capabilities lists the options the help text mentions, such as "-R"
+    // or "--json", and lower-case features the tool reports, such as curl's
+    // "http2".
+    Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Has reports whether the tool has the capability.
+func (i Info) Has(capability string) bool {
+    for _, c := range i.Capabilities {
+        if c == capability {
+            return true
+        }
+    }
+    return false
+}
+
+// AtLeast reports whether the tool's version is min or later. Versions are
+// compared number by number; an unknown version is assumed to be recent
+// enough.
+func (i Info) AtLeast(min string) bool {
+    if i.Version == "" {
+        return true
+    }
+    return compareVersions(i.Version, min) >= 0
+}
+
+// probeTimeout bounds each run of a probed tool.
+const probeTimeout = 2 * time.Second
+
+// cache holds the probed tools by path, loaded from disk on first use.
+var cache struct {
+    sync.Mutex
+    loaded bool
+    infos  map[string]Info
+}
+
+// cachePath returns the file probe results are kept in.
+func cachePath() (string, error) {
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "complete-command", "capabilities.json"), nil
+}
+
+// Probe returns what bin, looked up on PATH, is and can do. Results are
+// cached on disk by the binary's path and modification time, so each
+// binary is only run once. It reports false when bin is not installed.
+// Several tools may be probed concurrently.
+func Probe(bin string) (Info, bool) {
+    path := lookPath(bin)
+    if path == "" {
+        return Info{}, false
+    }
+    if abs, err := filepath.Abs(path); err == nil {
+        path = abs
+    }
+    st, err := os.Stat(path)
+    if err != nil {
+        return Info{}, false
+    }
+    cache.Lock()
+    if !cache.loaded {
+        cache.infos = make(map[string]Info)
+        if p, err := cachePath(); err == nil {
+            if data, err := os.ReadFile(p); err == nil {
+                _ = json.Unmarshal(data, &cache.infos)
+            }
+        }
+        cache.loaded = true
+    }
+    info, ok := cache.infos[path]
+    cache.Unlock()
+    if ok && info.ModTime.Equal(st.ModTime()) && info.Size == st.Size() {
+        return info, true
+    }
+    info = probe(path)
+    info.ModTime, info.Size = st.ModTime(), st.Size()
+    cache.Lock()
+    defer cache.Unlock()
+    cache.infos[path] = info
+    if p, err := cachePath(); err == nil {
+        if data, err := json.MarshalIndent(cache.infos, "", "  "); err == nil {
+            _ = os.MkdirAll(filepath.Dir(p), 0o755)
+            _ = os.WriteFile(p, data, 0o644)
+        }
+    }
+    return info, true
+}
+
+// Capable reports whether bin is installed, at least at version min when
+// min is not empty, and has every one of capabilities. Tools without
+// requirements are only looked up on PATH, never run.
+func Capable(bin, min string, capabilities []string) bool {
+    if min == "" && len(capabilities) == 0 {
+        return Has(bin)
+    }
+    info, ok := Probe(bin)
+    if !ok || (min != "" && !info.AtLeast(min)) {
+        return false
+    }
+    for _, c := range capabilities {
+        if !info.Has(c) {
+            return false
+        }
+    }
+    return true
+}
+
+// probe runs the binary at path with --version and --help and reads what
+// they print.
+func probe(path string) Info {
+    version := run(path, "--version")
+    help := run(path, "--help")
+    info := parse(version, help)
+    info.Path = path
+    // BusyBox applets are links to the busybox binary.
+    if real, err := filepath.EvalSymlinks(path); err == nil && filepath.Base(real) == "busybox" {
+        info.Vendor = "busybox"
+    }
+    return info
+}
+
+// run returns the combined output of the binary with one argument. Errors
+// are ignored: many tools exit non-zero after printing their help.
+func run(path, arg string) string {
+    ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, path, arg)
+    cmd.Env = append(os.Environ(), "LC_ALL=C")
+    var out bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &out, &out
+    _ = cmd.Run()
+    return out.String()
+}
+
+var (
+    versionRe = regexp.MustCompile(`\d+(?:\.\d+)+`)
+    // optionRe matches options in help texts, such as "-R," or "--json".
+    optionRe = regexp.MustCompile(`(?:^|[\s,\[(|])(--?[A-Za-z0-9][\w-]*)`)
+    // clusterRe matches clustered short options in usage lines, as in
+    // BusyBox's "grep [-HhnlLoqvsrRiwFE]".
+    clusterRe = regexp.MustCompile(`\[-([A-Za-z0-9]{2,})\]`)
+)
+
+// vendors maps markers found in the version or help text, lower-cased, to
+// vendor names, most specific first.
+var vendors = []struct{ marker, name string }{
+    {"busybox", "busybox"},
+    {"ripgrep", "ripgrep"},
+    {"(gnu ", "gnu"},
+    {"bsd", "bsd"},
+}
+
+// parse reads the vendor, version and capabilities of a tool from the
+// output of --version and --help.
+func parse(version, help string) Info {
+    var info Info
+    text := strings.ToLower(version + "\n" + help)
+    for _, v := range vendors {
+        if strings.Contains(text, v.marker) {
+            info.Vendor = v.name
+            break
+        }
+    }
+    for _, out := range []string{version, help} {
+        if line := firstLine(out); line != "" {
+            if v := versionRe.FindString(line); v != "" {
+                info.Version = v
+                break
+            }
+        }
+    }
+    seen := make(map[string]bool)
+    add := func(c string) {
+        if !seen[c] {
+            seen[c] = true
+            info.Capabilities = append(info.Capabilities, c)
+        }
+    }
+    for _, m := range clusterRe.FindAllStringSubmatch(help, -1) {
+        for _, r := range m[1] {
+            add("-" + string(r))
+        }
+    }
+    for _, m := range optionRe.FindAllStringSubmatch(help, -1) {
+        add(strings.TrimRight(m[1], "-"))
+    }
+    // curl lists what it was built with on a Features line.
+    for _, line := range strings.Split(version, "\n") {
+        if rest, ok := strings.CutPrefix(line, "Features:"); ok {
+            for _, f := range strings.Fields(rest) {
+                add(strings.ToLower(f))
+            }
+        }
+    }
+    return info
+}
+
+// firstLine returns the first non-blank line of s.
+func firstLine(s string) string {
+    for _, line := range strings.Split(s, "\n") {
+        if strings.TrimSpace(line) != "" {
+            return line
+        }
+    }
+    return ""
+}
+
+// compareVersions compares dotted version numbers, returning -1, 0 or 1.
+// Missing numbers count as zero, so "1.2" equals "1.2.0".
+func compareVersions(a, b string) int {
+    as, bs := strings.Split(a, "."), strings.Split(b, ".")
+    for i := 0; i < len(as) || i < len(bs); i++ {
+        var x, y int
+        if i < len(as) {
+            x, _ = strconv.Atoi(as[i])
+        }
+        if i < len(bs) {
+            y, _ = strconv.Atoi(bs[i])
+        }
+        switch {
+        case x < y:
+            return -1
+        case x > y:
+            return 1
+        }
+    }
+    return 0
+}
@@ -0,0 +1,112 @@
+package detect
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        name, version, help string
+        vendor, ver         string
+        has                 []string
+        lacks               []string
+    }{
+        {
+            "gnu grep",
+            "grep (GNU grep) 3.11\nCopyright (C) 2023 Free Software Foundation, Inc.\n",
+            "Usage: grep [OPTION]... PATTERNS [FILE]...\n  -w, --word-regexp         match only whole words\n  -R, --dereference-recursive  likewise, but follow all symlinks\n",
+            "gnu", "3.11", []string{"-w", "--word-regexp", "-R"}, []string{"-P"},
+        },
+        {
+            "busybox grep",
+            "grep: unrecognized option '--version'\nBusyBox v1.36.1 (2024-06-10 07:11:47 UTC) multi-call binary.\n",
+            "BusyBox v1.36.1 (2024-06-10 07:11:47 UTC) multi-call binary.\n\nUsage: grep [-HhnlLoqvsrRiwFE] [-m N] [-A|B|C N] { PATTERN | -e PATTERN... | -f FILE... } [FILE]...\n",
+            "busybox", "1.36.1", []string{"-R", "-w", "-m", "-e"}, []string{"--word-regexp"},
+        },
+        {
+            "ripgrep",
+            "ripgrep 14.1.0\n\nfeatures:+pcre2\n",
+            "",
+            "ripgrep", "14.1.0", nil, nil,
+        },
+        {
+            "curl",
+            "curl 8.5.0 (x86_64-pc-linux-gnu) libcurl/8.5.0 OpenSSL/3.0.13\nProtocols: dict file ftp http https\nFeatures: alt-svc HTTP2 HTTPS-proxy IPv6 SSL\n",
+            "Usage: curl [options...] <url>\n -o, --output <file>  Write to file instead of stdout\n",
+            "", "8.5.0", []string{"http2", "ipv6", "-o", "--output"}, []string{"HTTP2"},
+        },
+    }
+    for _, tt := range tests {
+        info := parse(tt.version, tt.help)
+        if info.Vendor != tt.vendor || info.Version != tt.ver {
+            t.Errorf("%s: vendor, version = %q, %q, want %q, %q", tt.name, info.Vendor, info.Version, tt.vendor, tt.ver)
+        }
+        for _, c := range tt.has {
+            if !info.Has(c) {
+                t.Errorf("%s: missing capability %q in %q", tt.name, c, info.Capabilities)
+            }
+        }
+        for _, c := range tt.lacks {
+            if info.Has(c) {
+                t.Errorf("%s: unexpected capability %q", tt.name, c)
+            }
+        }
+    }
+}
+
+func TestAtLeast(t *testing.T) {
+    info := Info{Version: "1.36.1"}
+    for min, want := range map[string]bool{"1.36": true, "1.36.1": true, "1.36.2": false, "1.4": true, "2": false} {
+        if got := info.AtLeast(min); got != want {
+            t.Errorf("AtLeast(%s) = %v, want %v", min, got, want)
+        }
+    }
+    if !(Info{}).AtLeast("99") {
+        t.Error("unknown version should be assumed recent enough")
+    }
+}
+
+// TestProbeCache checks that a tool is only run again once it changes.
+func TestProbeCache(t *testing.T) {
+    dir := t.TempDir()
+    t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+    t.Setenv("PATH", dir)
+    cache.loaded = false
+    t.Cleanup(func() { cache.loaded = false })
+    bin := filepath.Join(dir, "fake")
+    write := func(version string, mtime time.Time) {
+        script := "#!/bin/sh\necho \"fake (GNU fake) " + version + "\"\necho '  -R, --recursive'\n"
+        if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.Chtimes(bin, mtime, mtime); err != nil {
+            t.Fatal(err)
+        }
+    }
+    old := time.Now().Add(-time.Hour).Truncate(time.Second)
+    write("1.0", old)
+    info, ok := Probe("fake")
+    if !ok || info.Version != "1.0" || info.Vendor != "gnu" || !info.Has("--recursive") {
+        t.Fatalf("Probe = %+v, %v", info, ok)
+    }
+    if !Capable("fake", "1.0", []string{"-R"}) || Capable("fake", "2", nil) || Capable("fake", "", []string{"-x"}) {
+        t.Error("Capable does not follow the probed info")
+    }
+    // The cache file is used even after a restart.
+    cache.loaded = false
+    os.Remove(bin)
+    write("2.0", old)
+    if info, _ := Probe("fake"); info.Version != "1.0" {
+        t.Errorf("unchanged binary probed again: %+v", info)
+    }
+    write("2.0", old.Add(time.Minute))
+    if info, _ := Probe("fake"); info.Version != "2.0" {
+        t.Errorf("changed binary not probed again: %+v", info)
+    }
+    if _, ok := Probe("missing"); ok {
+        t.Error("missing tool probed")
+    }
+}
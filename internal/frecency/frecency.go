@@ -0,0 +1,230 @@
+// Package frecency tracks how often and how recently each action is used, so
+// the command palette can rank frequently used actions first.  Scores follow
+// the familiar frecency scheme: every use counts once, and the count is
+// weighted by how long ago the action was last used.  Counts age out as the
+// total grows so that old habits eventually give way to new ones.
+package frecency
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BlackOrder/complete-command/internal/statefile"
+)
+
+// MaxTotal is the sum of counts above which all counts are aged.
+const MaxTotal = 1000
+
+// Weight is the share of the frecency score in a blended ranking; the rest
+// comes from the match score.
+const Weight = 0.3
+
+// Record is the usage of one action.
+type Record struct {
+	Count float64   `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Store holds the usage records keyed by action ID.
+type Store struct {
+	Actions map[string]*Record `json:"actions"`
+}
+
+// Path returns the state file location:
+// $XDG_STATE_HOME/complete-command/frecency.json, falling back to
+// ~/.local/state when XDG_STATE_HOME is unset.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "complete-command", "frecency.json"), nil
+}
+
+// Load reads the store from disk.  A missing file yields an empty store, as
+// does a file that does not parse, which the next visit overwrites; usage
+// counts are not worth failing over.
+func Load() (*Store, error) {
+	path, err := Path()
+	if err != nil {
+		return newStore(), err
+	}
+	return read(path)
+}
+
+func newStore() *Store {
+	return &Store{Actions: make(map[string]*Record)}
+}
+
+// read reads the store at path.
+func read(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newStore(), nil
+	}
+	if err != nil {
+		return newStore(), err
+	}
+	s := newStore()
+	if err := json.Unmarshal(data, s); err != nil {
+		return newStore(), nil
+	}
+	if s.Actions == nil {
+		s.Actions = make(map[string]*Record)
+	}
+	return s, nil
+}
+
+// write replaces the store at path atomically.
+func write(path string, s *Store) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.Write(path, data)
+}
+
+// Save writes the store to disk, replacing the file atomically.  Prefer
+// Visit, which keeps the visits of other sessions meanwhile.
+func Save(s *Store) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	unlock, err := statefile.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return write(path, s)
+}
+
+// Visit records a use of the action id in the store on disk, re-read under
+// the lock so that sessions finishing at once do not lose each other's
+// visits.
+func Visit(id string) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	unlock, err := statefile.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s, err := read(path)
+	if err != nil {
+		return err
+	}
+	s.Visit(id, time.Now())
+	return write(path, s)
+}
+
+// Visit records a use of the action id at now.  When the total count exceeds
+// MaxTotal every count is scaled down and records falling below one use are
+// forgotten.
+func (s *Store) Visit(id string, now time.Time) {
+	r := s.Actions[id]
+	if r == nil {
+		r = &Record{}
+		s.Actions[id] = r
+	}
+	r.Count++
+	r.Last = now
+	total := 0.0
+	for _, r := range s.Actions {
+		total += r.Count
+	}
+	if total <= MaxTotal {
+		return
+	}
+	for k, r := range s.Actions {
+		r.Count *= 0.9 * MaxTotal / total
+		if r.Count < 1 {
+			delete(s.Actions, k)
+		}
+	}
+}
+
+// Score returns the frecency of the action id at now: its use count weighted
+// by the time since its last use.  Unknown actions score zero.
+func (s *Store) Score(id string, now time.Time) float64 {
+	r := s.Actions[id]
+	if r == nil {
+		return 0
+	}
+	age := now.Sub(r.Last)
+	switch {
+	case age < time.Hour:
+		return r.Count * 4
+	case age < 24*time.Hour:
+		return r.Count * 2
+	case age < 7*24*time.Hour:
+		return r.Count * 0.5
+	}
+	return r.Count * 0.25
+}
+
+// Top returns up to n action IDs with the highest scores at now, best first.
+// Ties are broken by the most recent use.
+func (s *Store) Top(n int, now time.Time) []string {
+	ids := make([]string, 0, len(s.Actions))
+	for id := range s.Actions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		si, sj := s.Score(ids[i], now), s.Score(ids[j], now)
+		if si != sj {
+			return si > sj
+		}
+		if li, lj := s.Actions[ids[i]].Last, s.Actions[ids[j]].Last; !li.Equal(lj) {
+			return li.After(lj)
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
+
+// Blend combines match scores with frecency scores for the same candidates.
+// Both are normalised to [0, 1] over the candidates before mixing, with
+// frecency contributing Weight of the result.
+func Blend(match, frec []float64) []float64 {
+	m, f := normalize(match), normalize(frec)
+	out := make([]float64, len(match))
+	for i := range out {
+		out[i] = (1-Weight)*m[i] + Weight*f[i]
+	}
+	return out
+}
+
+// normalize scales xs linearly onto [0, 1].  When all values are equal they
+// map to 1 if positive and 0 otherwise.
+func normalize(xs []float64) []float64 {
+	out := make([]float64, len(xs))
+	if len(xs) == 0 {
+		return out
+	}
+	lo, hi := xs[0], xs[0]
+	for _, x := range xs {
+		lo, hi = min(lo, x), max(hi, x)
+	}
+	for i, x := range xs {
+		switch {
+		case hi > lo:
+			out[i] = (x - lo) / (hi - lo)
+		case x > 0:
+			out[i] = 1
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package frecency
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScoreAndTop(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := &Store{Actions: make(map[string]*Record)}
+	for i := 0; i < 5; i++ {
+		s.Visit("old", now.Add(-30*24*time.Hour))
+	}
+	s.Visit("fresh", now.Add(-time.Minute))
+	s.Visit("daily", now.Add(-2*time.Hour))
+	s.Visit("daily", now.Add(-2*time.Hour))
+
+	if got := s.Score("old", now); got != 5*0.25 {
+		t.Errorf("Score(old) = %v, want 1.25", got)
+	}
+	if got := s.Score("missing", now); got != 0 {
+		t.Errorf("Score(missing) = %v, want 0", got)
+	}
+	if got, want := s.Top(2, now), []string{"fresh", "daily"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Top(2) = %v, want %v", got, want)
+	}
+}
+
+func TestVisitAging(t *testing.T) {
+	now := time.Now()
+	s := &Store{Actions: map[string]*Record{
+		"busy": {Count: MaxTotal, Last: now},
+		"rare": {Count: 1, Last: now},
+	}}
+	s.Visit("busy", now)
+	if _, ok := s.Actions["rare"]; ok {
+		t.Error("rare action survived aging")
+	}
+	if c := s.Actions["busy"].Count; c >= MaxTotal {
+		t.Errorf("busy count = %v, want it aged below %d", c, MaxTotal)
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := Visit("net/ping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Visit("net/ping"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Actions["net/ping"]; r == nil || r.Count != 2 {
+		t.Fatalf("record = %+v, want count 2", r)
+	}
+}
+
+// TestVisitCorrupt checks that a state file that does not parse is started
+// over instead of blocking every later visit.
+func TestVisitCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	path := filepath.Join(dir, "complete-command", "frecency.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"actions": `), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Load(); err != nil || len(s.Actions) != 0 {
+		t.Fatalf("Load() = %+v, %v; want an empty store", s, err)
+	}
+	if err := Visit("net/ping"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Actions["net/ping"]; r == nil || r.Count != 1 {
+		t.Fatalf("record = %+v, want count 1", r)
+	}
+}
+
+// TestVisitConcurrent checks that visits finishing at once are all kept.
+func TestVisitConcurrent(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Visit("net/ping"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Actions["net/ping"]; r == nil || r.Count != 8 {
+		t.Fatalf("record = %+v, want count 8", r)
+	}
+}
+
+func TestBlend(t *testing.T) {
+	// A slightly weaker match used often outranks a slightly better one
+	// never used, but frecency cannot lift a poor match over a good one.
+	got := Blend([]float64{100, 90, 10}, []float64{0, 10, 10})
+	if !(got[1] > got[0] && got[0] > got[2]) {
+		t.Errorf("Blend() = %v, want [1] > [0] > [2]", got)
+	}
+	if got := Blend([]float64{5, 5}, []float64{0, 0}); got[0] != 1-Weight || got[1] != 1-Weight {
+		t.Errorf("Blend(equal) = %v", got)
+	}
+}
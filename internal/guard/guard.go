@@ -0,0 +1,267 @@
+// Package guard looks for the risky parts of a command before it is inserted
+// into the prompt or run: privilege escalation, destructive commands and
+// flags, and writes outside the working directory.
+package guard
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// Kind is the kind of risk a finding points out.
+type Kind int
+
+const (
+	Privilege Kind = iota
+	Destructive
+	Write
+)
+
+// String names the kind for display.
+func (k Kind) String() string {
+	switch k {
+	case Privilege:
+		return "privilege escalation"
+	case Destructive:
+		return "destructive"
+	case Write:
+		return "writes outside the working directory"
+	}
+	return "unknown"
+}
+
+// Level returns how dangerous a finding of the kind is.
+func (k Kind) Level() registry.Level {
+	if k == Write {
+		return registry.Medium
+	}
+	return registry.High
+}
+
+// Finding is a risky word of a command.
+type Finding struct {
+	Kind Kind
+	// Start and End are the byte offsets of the word in the command.
+	Start, End int
+	Reason     string
+}
+
+// Level returns the highest level of the findings, None for none.
+func Level(fs []Finding) registry.Level {
+	l := registry.None
+	for _, f := range fs {
+		if f.Kind.Level() > l {
+			l = f.Kind.Level()
+		}
+	}
+	return l
+}
+
+// Assess returns the danger of running cmd, which act rendered for tool, in
+// dir: the higher of the level the registry declares and the level of what
+// Check finds.
+func Assess(act registry.Action, tool, cmd, dir string) (registry.Level, []Finding) {
+	fs := Check(cmd, dir)
+	l := act.DangerLevel(tool)
+	if fl := Level(fs); fl > l {
+		l = fl
+	}
+	return l, fs
+}
+
+// privileged lists commands that run what follows them as another user.
+var privileged = map[string]bool{"sudo": true, "doas": true, "su": true, "pkexec": true, "run0": true}
+
+// destructiveCommands lists commands that delete or overwrite data or stop
+// the system.
+var destructiveCommands = map[string]bool{
+	"rm": true, "rmdir": true, "shred": true, "dd": true, "wipefs": true, "fdisk": true,
+	"parted": true, "truncate": true, "userdel": true, "groupdel": true, "kill": true,
+	"killall": true, "pkill": true, "reboot": true, "shutdown": true, "poweroff": true,
+}
+
+// destructiveFlags lists flags that make any command destructive.
+var destructiveFlags = map[string]bool{
+	"--force": true, "--no-preserve-root": true, "--hard": true, "--delete": true,
+	"-delete": true, "--purge": true, "--force-with-lease": true,
+}
+
+// outputFlags lists, per command, the flags whose value is a file or
+// directory the command writes to.  A flag ending in '=' only takes its
+// value attached.
+var outputFlags = map[string][]string{
+	"curl":  {"-o", "--output"},
+	"wget":  {"-O", "--output-document"},
+	"sort":  {"-o", "--output"},
+	"unzip": {"-d"},
+	"7z":    {"-o"},
+	"dd":    {"of="},
+}
+
+// redirect matches output redirections such as ">", "2>>file" and "&>file",
+// but not duplications such as "2>&1".
+var redirect = regexp.MustCompile(`^(?:\d*|&)>>?(.*)$`)
+
+// Check returns the risky words of cmd, assuming it is run in dir.  It knows
+// the common commands and flags; an unknown command can still be harmful.
+func Check(cmd, dir string) []Finding {
+	var fs []Finding
+	add := func(w shellquote.Word, k Kind, reason string) {
+		fs = append(fs, Finding{Kind: k, Start: w.Start, End: w.End, Reason: reason})
+	}
+	// writes reports a finding when path, given by word w, is outside dir.
+	writes := func(w shellquote.Word, path string) {
+		if path != "" && path != "-" && path != "/dev/null" && outside(path, dir) {
+			add(w, Write, "writes to "+path)
+		}
+	}
+
+	words := shellquote.Words(cmd)
+	var name string
+	var args []shellquote.Word
+	// escalated is set after a privileged command, whose options come
+	// before the command it runs.
+	escalated := false
+	// finish checks the arguments of a command once all of them are known.
+	finish := func() {
+		switch name {
+		case "cp", "mv", "install", "ln":
+			if n := len(operands(args)); n >= 2 {
+				w := operands(args)[n-1]
+				writes(w, w.Text)
+			}
+		case "tee":
+			for _, w := range operands(args) {
+				writes(w, w.Text)
+			}
+		}
+		name, args, escalated = "", nil, false
+	}
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		raw := cmd[w.Start:w.End]
+		if isOperator(raw) {
+			finish()
+			continue
+		}
+		if m := redirect.FindStringSubmatch(raw); m != nil && !strings.HasPrefix(m[1], "&") {
+			var target string
+			if m[1] == "" {
+				if i+1 < len(words) {
+					i++
+					w = words[i]
+					target = w.Text
+				}
+			} else if t := redirect.FindStringSubmatch(w.Text); t != nil {
+				target = t[1]
+			}
+			writes(w, target)
+			continue
+		}
+		if name == "" {
+			switch {
+			case escalated && strings.HasPrefix(w.Text, "-"):
+				if (w.Text == "-u" || w.Text == "-g" || w.Text == "--user" || w.Text == "--group") && i+1 < len(words) {
+					i++
+				}
+				continue
+			case privileged[w.Text]:
+				add(w, Privilege, "runs the command as "+runsAs(w.Text, words[i+1:]))
+				// The next word names the command run.
+				escalated = true
+				continue
+			case destructiveCommands[w.Text] || strings.HasPrefix(w.Text, "mkfs"):
+				add(w, Destructive, w.Text+" deletes or overwrites data")
+			}
+			name = w.Text
+			continue
+		}
+		args = append(args, w)
+		switch {
+		case destructiveFlags[w.Text]:
+			add(w, Destructive, w.Text+" skips safety checks or deletes data")
+		case (name == "chmod" || name == "chown" || name == "chgrp") && (w.Text == "-R" || w.Text == "--recursive"):
+			add(w, Destructive, name+" "+w.Text+" changes a whole tree")
+		case name == "tar" && isTarCreate(w.Text) && i+1 < len(words):
+			i++
+			args = append(args, words[i])
+			writes(words[i], words[i].Text)
+			continue
+		}
+		for _, flag := range outputFlags[name] {
+			switch {
+			case w.Text == flag && !strings.HasSuffix(flag, "=") && i+1 < len(words):
+				i++
+				args = append(args, words[i])
+				writes(words[i], words[i].Text)
+			case strings.HasPrefix(w.Text, flag) && len(w.Text) > len(flag):
+				value := strings.TrimPrefix(strings.TrimPrefix(w.Text, flag), "=")
+				writes(w, value)
+			}
+		}
+	}
+	finish()
+	return fs
+}
+
+// runsAs describes the user a privileged command runs its command as.
+func runsAs(cmd string, rest []shellquote.Word) string {
+	for i, w := range rest {
+		if (w.Text == "-u" || w.Text == "--user") && i+1 < len(rest) && cmd != "su" {
+			return rest[i+1].Text
+		}
+		if !strings.HasPrefix(w.Text, "-") {
+			break
+		}
+	}
+	return "root"
+}
+
+// isTarCreate reports whether w is a cluster of short tar options creating
+// an archive in the file named by the next word, such as "-czf".
+func isTarCreate(w string) bool {
+	w = strings.TrimPrefix(w, "-")
+	return strings.HasPrefix(w, "c") && strings.HasSuffix(w, "f") && !strings.HasPrefix(w, "-")
+}
+
+// operands returns the words that are not options.
+func operands(args []shellquote.Word) []shellquote.Word {
+	var ops []shellquote.Word
+	for _, w := range args {
+		if !strings.HasPrefix(w.Text, "-") {
+			ops = append(ops, w)
+		}
+	}
+	return ops
+}
+
+// isOperator reports whether w separates commands.
+func isOperator(w string) bool {
+	switch w {
+	case "|", "||", "&&", ";", "&":
+		return true
+	}
+	return false
+}
+
+// outside reports whether path is outside dir.  A leading "~" stands for the
+// home directory.
+func outside(path, dir string) bool {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return true
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
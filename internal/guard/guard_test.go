@@ -0,0 +1,59 @@
+package guard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		words []string
+		kinds []Kind
+	}{
+		{"ls -la", nil, nil},
+		{"find . -delete 2>/dev/null", []string{"-delete"}, []Kind{Destructive}},
+		{"sudo useradd -m bob", []string{"sudo"}, []Kind{Privilege}},
+		{"sudo -u www rm -rf cache", []string{"sudo", "rm"}, []Kind{Privilege, Destructive}},
+		{"git push --force", []string{"--force"}, []Kind{Destructive}},
+		{"chmod -R 755 .", []string{"-R"}, []Kind{Destructive}},
+		{"tar -czf ../out.tgz src", []string{"../out.tgz"}, []Kind{Write}},
+		{"tar -czf out.tgz src", nil, nil},
+		{"tar -xzf ../in.tgz", nil, nil},
+		{"curl -o /tmp/page https://example.com", []string{"/tmp/page"}, []Kind{Write}},
+		{"7z x a.zip -o/opt/a", []string{"-o/opt/a"}, []Kind{Write}},
+		{"echo hi > /etc/motd 2>&1", []string{"/etc/motd"}, []Kind{Write}},
+		{"echo hi >>'../a b'", []string{">>'../a b'"}, []Kind{Write}},
+		{"cp a b /srv", []string{"/srv"}, []Kind{Write}},
+		{"cp /srv/a b", nil, nil},
+		{"ps aux | tee sub/out.txt", nil, nil},
+		{"echo sudo rm", nil, nil},
+	}
+	for _, tt := range tests {
+		fs := Check(tt.cmd, "/home/u/proj")
+		var words []string
+		var kinds []Kind
+		for _, f := range fs {
+			words = append(words, tt.cmd[f.Start:f.End])
+			kinds = append(kinds, f.Kind)
+		}
+		if !reflect.DeepEqual(words, tt.words) || !reflect.DeepEqual(kinds, tt.kinds) {
+			t.Errorf("Check(%q) = %q %v, want %q %v", tt.cmd, words, kinds, tt.words, tt.kinds)
+		}
+	}
+}
+
+func TestAssess(t *testing.T) {
+	act := registry.Action{Danger: "low", TemplateDanger: map[string]string{"b": "none"}}
+	if l, _ := Assess(act, "a", "ls", "/"); l != registry.Low {
+		t.Errorf("declared level = %v, want low", l)
+	}
+	if l, _ := Assess(act, "b", "ls > /etc/ls", "/tmp"); l != registry.Medium {
+		t.Errorf("level with a write = %v, want medium", l)
+	}
+	if l, _ := Assess(act, "a", "sudo ls", "/"); l != registry.High {
+		t.Errorf("level with sudo = %v, want high", l)
+	}
+}
@@ -0,0 +1,101 @@
+// Package history records the commands built with complete-command.  Each
+// finished action is appended as one JSON object per line to a history file
+// in the XDG data directory, so entries can later be browsed and reopened
+// with their field values.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is a single built command.
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Action  string                 `json:"action"`
+	Tool    string                 `json:"tool"`
+	Values  map[string]interface{} `json:"values,omitempty"`
+	Command string                 `json:"command"`
+}
+
+// Path returns the history file location:
+// $XDG_DATA_HOME/complete-command/history.jsonl, falling back to
+// ~/.local/share when XDG_DATA_HOME is unset.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "complete-command", "history.jsonl"), nil
+}
+
+// Append adds e to the history file, creating it if necessary.  A zero Time
+// is set to the current time.
+func Append(e Entry) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	// A single write keeps concurrent appends from interleaving lines.
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns all history entries, newest first.  A missing file yields no
+// entries; lines that cannot be decoded, such as a line cut short by a crash,
+// are skipped.
+func Load() ([]Entry, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Action == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
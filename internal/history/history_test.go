@@ -0,0 +1,51 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestAppendLoad checks that entries round-trip newest first and that
+// corrupt lines are skipped.
+func TestAppendLoad(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	entries, err := Load()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Load() on empty history = %v, %v", entries, err)
+	}
+	first := Entry{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Action: "net/ping", Tool: "ping",
+		Values: map[string]interface{}{"host": "example.com", "count": 4.0}, Command: "ping -c 4 example.com"}
+	if err := Append(first); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := Path()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"action\": \"broken\n")
+	f.Close()
+	if err := Append(Entry{Action: "proc/top", Tool: "htop", Command: "htop"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Load() returned %d entries, want 2: %v", len(entries), entries)
+	}
+	if entries[0].Action != "proc/top" || entries[0].Time.IsZero() {
+		t.Errorf("newest entry = %+v", entries[0])
+	}
+	if !reflect.DeepEqual(entries[1], first) {
+		t.Errorf("oldest entry = %+v, want %+v", entries[1], first)
+	}
+	if filepath.Base(filepath.Dir(path)) != "complete-command" {
+		t.Errorf("history path %s is not in the complete-command data dir", path)
+	}
+}
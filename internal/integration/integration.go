@@ -10,8 +10,9 @@ import (
 // ToggleShellIntegration installs or uninstalls shell integration for the
 // complete‑command tool. It detects the current shell from the SHELL
 // environment variable and modifies the appropriate rc file in the user's
-// home directory. The integration binds Ctrl+G to run the tool and insert
-// the resulting command into the current prompt. On installation, it adds
+// home directory. The integration binds Ctrl+G to run the tool on the
+// current prompt, so a command already typed opens pre-filled, and replace
+// the prompt with the resulting command. On installation, it adds
 // a BEGIN/END marked section; on uninstallation, it removes that section.
 // It returns a message indicating whether the integration was installed
 // or removed. Errors during reading or writing the rc file are returned.
@@ -75,7 +76,7 @@ func ToggleShellIntegration(install *bool) (string, error) {
         integrationSnippet = fmt.Sprintf(`%s
 cmdcraft() {
   local out
-  out="$("%s" "$@")" || return
+  out="$("%s" --line "$READLINE_LINE" "$@")" || return
   [[ -z "$out" ]] && return
   READLINE_LINE="$out"
   READLINE_POINT=${#READLINE_LINE}
@@ -87,7 +88,7 @@ bind -x '"\C-g":cmdcraft'
         integrationSnippet = fmt.Sprintf(`%s
 cmdcraft() {
   local out
-  out="$("%s" "$@")" || return
+  out="$("%s" --line "$BUFFER" "$@")" || return
   [[ -z "$out" ]] && return
   LBUFFER="$out"
   RBUFFER=""
@@ -100,7 +101,7 @@ bindkey '^G' cmdcraft
     case "fish":
         integrationSnippet = fmt.Sprintf(`%s
 function cmdcraft
-    set -l out (%s)
+    set -l out (%s --line (commandline | string collect --allow-empty))
     or return
     if test -n "$out"
         commandline -r -- $out
@@ -114,7 +115,7 @@ bind \cg cmdcraft
         integrationSnippet = fmt.Sprintf(`%s
 cmdcraft() {
   local out
-  out="$("%s" "$@")" || return
+  out="$("%s" --line "$READLINE_LINE" "$@")" || return
   [[ -z "$out" ]] && return
   READLINE_LINE="$out"
   READLINE_POINT=${#READLINE_LINE}
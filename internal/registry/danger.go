@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is how much harm a command can do when run carelessly.  Levels are
+// ordered, None being the lowest.
+type Level int
+
+const (
+	None Level = iota
+	Low
+	Medium
+	High
+)
+
+// DangerLevels lists the level names accepted in the registry, lowest
+// first.
+var DangerLevels = []string{"none", "low", "medium", "high"}
+
+// String returns the registry name of the level.
+func (l Level) String() string {
+	if l < None || int(l) >= len(DangerLevels) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return DangerLevels[l]
+}
+
+// ParseLevel parses a level name.  The empty string is None.
+func ParseLevel(s string) (Level, error) {
+	if s == "" {
+		return None, nil
+	}
+	for i, name := range DangerLevels {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown danger level %q (want one of %s)", s, strings.Join(DangerLevels, ", "))
+}
+
+// DangerLevel returns the declared danger of the action's command for tool:
+// the tool's entry in TemplateDanger when there is one, otherwise Danger.
+// Unknown names, which validation reports, count as High.
+func (a Action) DangerLevel(tool string) Level {
+	s := a.Danger
+	if d, ok := a.TemplateDanger[tool]; ok {
+		s = d
+	}
+	l, err := ParseLevel(s)
+	if err != nil {
+		return High
+	}
+	return l
+}
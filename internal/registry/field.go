@@ -0,0 +1,354 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultValue returns the field's initial value converted to the Go type
+// used for its field type: string for string, path, duration and size,
+// bool, int, float64, the chosen string for enum (the first choice when no
+// default is given) and []string for multi.
+func (f Field) DefaultValue() interface{} {
+	switch f.Type {
+	case "bool":
+		b, _ := f.Default.(bool)
+		return b
+	case "int":
+		switch v := f.Default.(type) {
+		case int:
+			return v
+		case int64:
+			return int(v)
+		case float64:
+			return int(v)
+		}
+		return 0
+	case "float":
+		switch v := f.Default.(type) {
+		case float32:
+			return float64(v)
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		}
+		return 0.0
+	case "enum":
+		if s, ok := f.Default.(string); ok {
+			for _, c := range f.Choices {
+				if c == s {
+					return c
+				}
+			}
+		}
+		if len(f.Choices) > 0 {
+			return f.Choices[0]
+		}
+		return ""
+	case "multi":
+		var entries []string
+		switch d := f.Default.(type) {
+		case string:
+			if d != "" {
+				entries = append(entries, d)
+			}
+		case []interface{}:
+			for _, e := range d {
+				entries = append(entries, fmt.Sprint(e))
+			}
+		}
+		return entries
+	case "duration", "size":
+		switch v := f.Default.(type) {
+		case string:
+			return v
+		case int, float64:
+			return fmt.Sprint(v)
+		}
+		return ""
+	default:
+		s, _ := f.Default.(string)
+		return s
+	}
+}
+
+// ParseValue converts text, such as a command-line argument, into a value of
+// the field's type.  For multi fields the text is a single entry.
+func (f Field) ParseValue(s string) (interface{}, error) {
+	switch f.Type {
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "1", "t", "true", "y", "yes", "on":
+			return true, nil
+		case "0", "f", "false", "n", "no", "off", "":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s must be true or false", f.name())
+	case "int":
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", f.name())
+		}
+		return n, nil
+	case "float":
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", f.name())
+		}
+		return n, nil
+	case "enum":
+		for _, c := range f.Choices {
+			if strings.EqualFold(c, s) {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of %s", f.name(), strings.Join(f.Choices, ", "))
+	case "duration", "size":
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return s, nil
+		}
+		if _, err := f.Quantity(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "multi":
+		if err := f.ValidateEntry(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return s, nil
+	}
+}
+
+// Coerce converts a loosely typed value, such as one decoded from JSON, into
+// a value of the field's type.  JSON numbers become int or float64, lists
+// become []string and strings are parsed with ParseValue.
+func (f Field) Coerce(v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case nil:
+		return f.DefaultValue(), nil
+	case string:
+		if f.Type == "multi" {
+			if vv == "" {
+				return []string{}, nil
+			}
+			if err := f.ValidateEntry(vv); err != nil {
+				return nil, err
+			}
+			return []string{vv}, nil
+		}
+		return f.ParseValue(vv)
+	case bool:
+		if f.Type == "bool" {
+			return vv, nil
+		}
+	case int:
+		switch f.Type {
+		case "int":
+			return vv, nil
+		case "float":
+			return float64(vv), nil
+		case "duration", "size":
+			return fmt.Sprint(vv), nil
+		}
+	case float64:
+		switch f.Type {
+		case "int":
+			if vv == float64(int(vv)) {
+				return int(vv), nil
+			}
+		case "float":
+			return vv, nil
+		case "duration", "size":
+			return strconv.FormatFloat(vv, 'f', -1, 64), nil
+		}
+	case []string:
+		if f.Type == "multi" {
+			return vv, f.Validate(vv)
+		}
+	case []interface{}:
+		if f.Type == "multi" {
+			entries := make([]string, 0, len(vv))
+			for _, e := range vv {
+				entries = append(entries, fmt.Sprint(e))
+			}
+			return entries, f.Validate(entries)
+		}
+	}
+	return nil, fmt.Errorf("%s has invalid value %v for type %s", f.name(), v, f.Type)
+}
+
+// Validate checks a field value against the field's constraints: Required
+// fields must be set, int and float values must lie within Min and Max, as
+// must durations in seconds and sizes in bytes, and path values must be
+// usable as a file name and satisfy the Must rule.
+// Values typed as text (for example "12" for an int field) are parsed
+// according to the field type.
+// The returned error message is suitable for showing next to the field.
+func (f Field) Validate(v interface{}) error {
+	if isEmpty(v) {
+		if f.Required {
+			return fmt.Errorf("%s is required", f.name())
+		}
+		return nil
+	}
+	switch f.Type {
+	case "int":
+		n, err := toFloat(v, true)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", f.name())
+		}
+		return f.checkRange(n)
+	case "float":
+		n, err := toFloat(v, false)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", f.name())
+		}
+		return f.checkRange(n)
+	case "duration", "size":
+		s, _ := v.(string)
+		n, err := f.Quantity(s)
+		if err != nil {
+			return err
+		}
+		return f.checkRange(n)
+	case "path":
+		s, _ := v.(string)
+		if strings.ContainsAny(s, "\x00\n") {
+			return fmt.Errorf("%s is not a valid path", f.name())
+		}
+		return f.checkPath(s)
+	case "multi":
+		if list, ok := v.([]string); ok {
+			for _, e := range list {
+				if err := f.ValidateEntry(e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Clamp limits n to the field's Min and Max bounds, when set.
+func (f Field) Clamp(n float64) float64 {
+	if f.Min != nil && n < *f.Min {
+		n = *f.Min
+	}
+	if f.Max != nil && n > *f.Max {
+		n = *f.Max
+	}
+	return n
+}
+
+// name is the label used in validation messages.
+func (f Field) name() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Key
+}
+
+func (f Field) checkRange(n float64) error {
+	// Bounds of durations and sizes are in seconds and bytes.
+	unit := map[string]string{"duration": " seconds", "size": " bytes"}[f.Type]
+	if f.Min != nil && n < *f.Min {
+		return fmt.Errorf("%s must be at least %s%s", f.name(), strconv.FormatFloat(*f.Min, 'g', -1, 64), unit)
+	}
+	if f.Max != nil && n > *f.Max {
+		return fmt.Errorf("%s must be at most %s%s", f.name(), strconv.FormatFloat(*f.Max, 'g', -1, 64), unit)
+	}
+	return nil
+}
+
+// isEmpty reports whether a value counts as missing for a required field.
+func isEmpty(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(vv) == ""
+	case []string:
+		return len(vv) == 0
+	}
+	return false
+}
+
+// toFloat converts a numeric or textual value to float64.  With whole set,
+// only integral values are accepted.
+func toFloat(v interface{}, whole bool) (float64, error) {
+	var n float64
+	switch vv := v.(type) {
+	case int:
+		n = float64(vv)
+	case int64:
+		n = float64(vv)
+	case float64:
+		n = vv
+	case string:
+		s := strings.TrimSpace(vv)
+		if whole {
+			i, err := strconv.ParseInt(s, 10, 64)
+			return float64(i), err
+		}
+		return strconv.ParseFloat(s, 64)
+	default:
+		return 0, fmt.Errorf("unsupported value %v", v)
+	}
+	if whole && n != float64(int64(n)) {
+		return 0, fmt.Errorf("%v is not a whole number", v)
+	}
+	return n, nil
+}
+
+// ValidateEntry checks a single entry of a multi field against the field's
+// Entry hint.  The hint is read as a shape: its words are placeholders and
+// the punctuation between them must appear in the entry in the same order.
+// "Header:Value" therefore requires an entry such as "Accept:text/html",
+// with a non-empty name before the separator.  A hint without punctuation,
+// or no hint at all, only requires a non-blank entry.
+func (f Field) ValidateEntry(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return fmt.Errorf("%s: entry must not be empty", f.Key)
+	}
+	seps := entrySeparators(f.Entry)
+	rest := entry
+	for i, sep := range seps {
+		j := strings.Index(rest, sep)
+		if j < 0 {
+			return fmt.Errorf("%s: entry %q does not match %q", f.Key, entry, f.Entry)
+		}
+		if i == 0 && strings.TrimSpace(rest[:j]) == "" {
+			return fmt.Errorf("%s: entry %q is missing the part before %q", f.Key, entry, sep)
+		}
+		rest = rest[j+len(sep):]
+	}
+	return nil
+}
+
+// entrySeparators returns the runs of punctuation that separate the words of
+// an Entry hint, e.g. [":"] for "Header:Value".
+func entrySeparators(hint string) []string {
+	var seps []string
+	var cur strings.Builder
+	inWord := false
+	for _, r := range hint {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if sep := strings.TrimSpace(cur.String()); sep != "" && inWord {
+				seps = append(seps, sep)
+			}
+			cur.Reset()
+			inWord = true
+			continue
+		}
+		if inWord || cur.Len() > 0 {
+			cur.WriteRune(r)
+		}
+	}
+	return seps
+}
@@ -0,0 +1,24 @@
+package registry
+
+// PackageManagers lists the package managers install hints are given for,
+// in the order they are looked for on the host: the candidates of
+// pkg/search, then Homebrew.
+var PackageManagers = []string{"apt", "dnf", "pacman", "yum", "zypper", "brew"}
+
+// InstallCommands maps each package manager to the command installing a
+// package, %s standing for the package name.
+var InstallCommands = map[string]string{
+	"apt":    "sudo apt install %s",
+	"dnf":    "sudo dnf install %s",
+	"pacman": "sudo pacman -S %s",
+	"yum":    "sudo yum install %s",
+	"zypper": "sudo zypper install %s",
+	"brew":   "brew install %s",
+}
+
+// Package returns the name of the package providing tool for the action
+// with the given package manager, and false when the registry names none.
+func (a Action) Package(tool, manager string) (string, bool) {
+	pkg, ok := a.Install[tool][manager]
+	return pkg, ok && pkg != ""
+}
@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SystemDir holds the system-wide registry overlays.  It is a variable so
+// tests and packagers can point it elsewhere.
+var SystemDir = "/etc/complete-command"
+
+// ProjectFile is the name of the project-local overlay, looked up in the
+// working directory and its parents.
+const ProjectFile = ".complete-command.yaml"
+
+// SearchPath returns the registry overlay files that exist on this system,
+// lowest precedence first:
+//
+//  1. $SystemDir/registry.yaml and $SystemDir/registry.d/*.yaml
+//  2. $XDG_CONFIG_HOME/complete-command/registry.d/*.yaml
+//     (~/.config/complete-command/registry.d when XDG_CONFIG_HOME is unset)
+//  3. the nearest .complete-command.yaml in the working directory or above
+//
+// Files within a registry.d directory are applied in lexical order.
+func SearchPath() []string {
+	var paths []string
+	if fileExists(filepath.Join(SystemDir, "registry.yaml")) {
+		paths = append(paths, filepath.Join(SystemDir, "registry.yaml"))
+	}
+	paths = append(paths, dropIn(filepath.Join(SystemDir, "registry.d"))...)
+	if dir, err := userConfigDir(); err == nil {
+		paths = append(paths, dropIn(filepath.Join(dir, "complete-command", "registry.d"))...)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if p := findUp(wd, ProjectFile); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// LoadLayered parses the built-in default registry and merges every overlay
+// from SearchPath over it.  Overlays that fail to load are skipped and their
+// errors returned together, alongside the registry built from the rest.
+func LoadLayered(defaults []byte) (*Registry, error) {
+	reg, err := Parse(bytes.NewReader(defaults))
+	if err != nil {
+		return nil, fmt.Errorf("built-in registry: %w", err)
+	}
+	var errs []error
+	for _, path := range SearchPath() {
+		overlay, err := Load(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		reg.Merge(overlay)
+	}
+	return reg, errors.Join(errs...)
+}
+
+// Merge applies overlay on top of r.  Actions are matched by ID: an overlay
+// action with disabled set removes the action, otherwise its non-empty
+// attributes replace those of the existing action.  Templates are merged per
+// tool, and a tool whose overlay template is empty (or null) is removed along
+// with its candidate entry.  Fields are merged by key, new fields being
+// appended.  Actions with a new ID are added at the end.
+func (r *Registry) Merge(overlay *Registry) {
+	for _, o := range overlay.Actions {
+		idx := -1
+		for i := range r.Actions {
+			if r.Actions[i].ID == o.ID {
+				idx = i
+				break
+			}
+		}
+		if o.Disabled {
+			if idx >= 0 {
+				r.Actions = append(r.Actions[:idx], r.Actions[idx+1:]...)
+			}
+			continue
+		}
+		if idx < 0 {
+			r.Actions = append(r.Actions, Action{ID: o.ID})
+			idx = len(r.Actions) - 1
+		}
+		r.Actions[idx].merge(o)
+	}
+}
+
+// merge applies the overlay action o to a.
+func (a *Action) merge(o Action) {
+	if o.Title != "" {
+		a.Title = o.Title
+	}
+	if o.Synonyms != nil {
+		a.Synonyms = o.Synonyms
+	}
+	if o.Candidates != nil {
+		a.Candidates = o.Candidates
+	}
+	if len(o.Template) > 0 && a.Template == nil {
+		a.Template = make(map[string]string)
+	}
+	tools := make([]string, 0, len(o.Template))
+	for tool := range o.Template {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		if t := o.Template[tool]; t != "" {
+			a.Template[tool] = t
+			if !contains(a.Candidates, tool) {
+				a.Candidates = append(a.Candidates, tool)
+			}
+			continue
+		}
+		delete(a.Template, tool)
+		a.Candidates = remove(a.Candidates, tool)
+	}
+	// Help texts are merged word by word; the maps are copied so the
+	// action being overlaid is left untouched.
+	if len(o.Explain) > 0 {
+		explain := make(map[string]map[string]string, len(a.Explain)+len(o.Explain))
+		for tool, words := range a.Explain {
+			explain[tool] = words
+		}
+		for tool, words := range o.Explain {
+			merged := make(map[string]string, len(explain[tool])+len(words))
+			for w, help := range explain[tool] {
+				merged[w] = help
+			}
+			for w, help := range words {
+				merged[w] = help
+			}
+			explain[tool] = merged
+		}
+		a.Explain = explain
+	}
+	if o.Danger != "" {
+		a.Danger = o.Danger
+	}
+	if len(o.TemplateDanger) > 0 {
+		danger := make(map[string]string, len(a.TemplateDanger)+len(o.TemplateDanger))
+		for tool, d := range a.TemplateDanger {
+			danger[tool] = d
+		}
+		for tool, d := range o.TemplateDanger {
+			danger[tool] = d
+		}
+		a.TemplateDanger = danger
+	}
+	if len(o.Install) > 0 {
+		install := make(map[string]map[string]string, len(a.Install)+len(o.Install))
+		for tool, pkgs := range a.Install {
+			install[tool] = pkgs
+		}
+		for tool, pkgs := range o.Install {
+			merged := make(map[string]string, len(install[tool])+len(pkgs))
+			for pm, pkg := range install[tool] {
+				merged[pm] = pkg
+			}
+			for pm, pkg := range pkgs {
+				merged[pm] = pkg
+			}
+			install[tool] = merged
+		}
+		a.Install = install
+	}
+	if len(o.Requires) > 0 {
+		requires := make(map[string]Requirement, len(a.Requires)+len(o.Requires))
+		for tool, r := range a.Requires {
+			requires[tool] = r
+		}
+		for tool, r := range o.Requires {
+			requires[tool] = r
+		}
+		a.Requires = requires
+	}
+	for _, f := range o.Fields {
+		replaced := false
+		for i := range a.Fields {
+			if a.Fields[i].Key == f.Key {
+				a.Fields[i] = f
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			a.Fields = append(a.Fields, f)
+		}
+	}
+}
+
+// dropIn lists the *.yaml files in dir in lexical order.
+func dropIn(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	sort.Strings(matches)
+	return matches
+}
+
+// userConfigDir honours XDG_CONFIG_HOME and falls back to ~/.config.
+func userConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+// findUp returns the first file called name in dir or one of its parents.
+func findUp(dir, name string) string {
+	for {
+		p := filepath.Join(dir, name)
+		if fileExists(p) {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && !st.IsDir()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
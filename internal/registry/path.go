@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathRules lists the values of a path field's must attribute: the path
+// must exist, must not exist, must be an existing directory or must be an
+// existing file.
+var PathRules = []string{"exist", "not-exist", "dir", "file"}
+
+// ExpandHome replaces a leading "~" in path with the home directory.  Paths
+// naming another user's home, such as "~bob", are returned unchanged.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// IsGlob reports whether path contains glob metacharacters.
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// checkPath checks path against the field's must rule, relative to the
+// working directory.  Patterns of fields with Glob set are not checked;
+// other fields take glob metacharacters literally.
+func (f Field) checkPath(path string) error {
+	if f.Must == "" || f.Glob && IsGlob(path) {
+		return nil
+	}
+	st, err := os.Stat(ExpandHome(path))
+	switch {
+	case f.Must == "not-exist":
+		if err == nil {
+			return fmt.Errorf("%s: %s already exists", f.name(), path)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s: %s does not exist", f.name(), path)
+	case f.Must == "dir" && !st.IsDir():
+		return fmt.Errorf("%s: %s is not a directory", f.name(), path)
+	case f.Must == "file" && st.IsDir():
+		return fmt.Errorf("%s: %s is a directory", f.name(), path)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package registry
 import (
     "io"
     "os"
+    "strings"
 
     yaml "gopkg.in/yaml.v3"
 )
@@ -18,8 +19,20 @@ type Field struct {
     Required    bool        `yaml:"required"`
     Min         *float64    `yaml:"min"`
     Max         *float64    `yaml:"max"`
+    // Step is how much +/- change a numeric field by, 1 by default.
+    Step        *float64    `yaml:"step"`
     ShowIf      string      `yaml:"showIf"`
     Entry       string      `yaml:"entry"`
+    // Help explains what the field does to the command, for explain mode.
+    Help        string      `yaml:"help"`
+    // Remember keeps the last value used in the config and restores it
+    // in place of the default.
+    Remember    bool        `yaml:"remember"`
+    // Must is what a path field's value must name, one of PathRules.
+    Must        string      `yaml:"must"`
+    // Glob lets the shell expand glob patterns in a path field's value,
+    // which is quoted otherwise.
+    Glob        bool        `yaml:"glob"`
 }
 
 // Action defines a single command‑building action.
@@ -30,6 +43,31 @@ type Action struct {
     Candidates []string          `yaml:"candidates"`
     Template   map[string]string `yaml:"template"`
     Fields     []Field           `yaml:"fields"`
+    // Explain maps a tool to help texts for the words of its commands,
+    // such as "-uu", keyed by the word as rendered.
+    Explain    map[string]map[string]string `yaml:"explain"`
+    // Danger is how harmful the action's commands are, one of
+    // DangerLevels. TemplateDanger overrides it for single tools.
+    Danger     string            `yaml:"danger"`
+    TemplateDanger map[string]string `yaml:"templateDanger"`
+    // Requires maps a tool to what its template needs beyond the tool
+    // being installed. Tools that fall short are not offered.
+    Requires   map[string]Requirement `yaml:"requires"`
+    // Install maps a tool to the name of its package for each of
+    // PackageManagers, for the hints shown when no tool is installed.
+    Install    map[string]map[string]string `yaml:"install"`
+    // Disabled removes the action when set in a registry overlay.
+    Disabled   bool              `yaml:"disabled"`
+}
+
+// Requirement is what a tool must provide for an action's template to work,
+// as found by probing the installed tool.
+type Requirement struct {
+    // MinVersion is the lowest version of the tool the template works with.
+    MinVersion   string   `yaml:"minVersion"`
+    // Capabilities lists options the tool's help must mention, such as
+    // "-R", or features it reports, such as curl's "http2".
+    Capabilities []string `yaml:"capabilities"`
 }
 
 // Registry holds a collection of actions loaded from YAML.
@@ -55,4 +93,22 @@ func Parse(r io.Reader) (*Registry, error) {
         return nil, err
     }
     return &reg, nil
-}
\ No newline at end of file
+}
+
+// Find returns the action whose ID, title or one of whose synonyms matches
+// name, ignoring case. It returns nil when no action matches.
+func (r *Registry) Find(name string) *Action {
+    name = strings.ToLower(name)
+    for i := range r.Actions {
+        act := &r.Actions[i]
+        if strings.ToLower(act.ID) == name || strings.ToLower(act.Title) == name {
+            return act
+        }
+        for _, syn := range act.Synonyms {
+            if strings.ToLower(syn) == name {
+                return act
+            }
+        }
+    }
+    return nil
+}
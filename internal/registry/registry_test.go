@@ -1,6 +1,11 @@
 package registry
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 // TestLoad ensures the registry YAML file is parsed and contains actions.
 func TestLoad(t *testing.T) {
@@ -12,3 +17,337 @@ func TestLoad(t *testing.T) {
 		t.Fatal("expected at least one action in registry")
 	}
 }
+
+// TestVisible checks that showIf conditions hide fields for other tools and
+// values, and that hidden fields do not satisfy dependent conditions.
+func TestVisible(t *testing.T) {
+	act := Action{Fields: []Field{
+		{Key: "method", Type: "enum"},
+		{Key: "data", Type: "string", ShowIf: "method!=GET"},
+		{Key: "hidden", Type: "bool", ShowIf: "tool=rg"},
+		{Key: "dep", Type: "bool", ShowIf: "hidden"},
+	}}
+	vis := act.Visible("grep", map[string]interface{}{"method": "GET", "hidden": true})
+	if vis["data"] || vis["hidden"] || vis["dep"] {
+		t.Fatalf("expected data, hidden and dep to be hidden, got %v", vis)
+	}
+	if !vis["method"] {
+		t.Fatalf("expected method to be visible")
+	}
+	vis = act.Visible("rg", map[string]interface{}{"method": "POST", "hidden": true})
+	if !vis["data"] || !vis["hidden"] || !vis["dep"] {
+		t.Fatalf("expected all fields visible, got %v", vis)
+	}
+}
+
+// TestValidateEntry checks multi entries against their Entry hint.
+func TestValidateEntry(t *testing.T) {
+	header := Field{Key: "header", Type: "multi", Entry: "Header:Value"}
+	for _, ok := range []string{"Accept:text/html", "X-Empty:", "A: b:c"} {
+		if err := header.ValidateEntry(ok); err != nil {
+			t.Errorf("ValidateEntry(%q) unexpected error: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "  ", "Accept", ":value"} {
+		if err := header.ValidateEntry(bad); err == nil {
+			t.Errorf("ValidateEntry(%q) expected error", bad)
+		}
+	}
+	path := Field{Key: "paths", Type: "multi", Entry: "path"}
+	if err := path.ValidateEntry("src/main.go"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestValidate covers required fields, numeric bounds and typed-in values.
+func TestValidate(t *testing.T) {
+	one, ten := 1.0, 10.0
+	cases := []struct {
+		f     Field
+		v     interface{}
+		valid bool
+	}{
+		{Field{Key: "host", Type: "string", Required: true}, "", false},
+		{Field{Key: "host", Type: "string", Required: true}, "  ", false},
+		{Field{Key: "host", Type: "string", Required: true}, "example.com", true},
+		{Field{Key: "user", Type: "string"}, "", true},
+		{Field{Key: "n", Type: "int", Min: &one, Max: &ten}, 0, false},
+		{Field{Key: "n", Type: "int", Min: &one, Max: &ten}, 11, false},
+		{Field{Key: "n", Type: "int", Min: &one, Max: &ten}, 5, true},
+		{Field{Key: "n", Type: "int", Min: &one}, "7", true},
+		{Field{Key: "n", Type: "int"}, "7.5", false},
+		{Field{Key: "n", Type: "int"}, "abc", false},
+		{Field{Key: "n", Type: "int", Min: &one}, "", true},
+		{Field{Key: "f", Type: "float", Min: &one}, 0.9, false},
+		{Field{Key: "f", Type: "float", Min: &one}, "1.5", true},
+		{Field{Key: "p", Type: "path"}, "a\nb", false},
+		{Field{Key: "p", Type: "path", Required: true}, "out.tgz", true},
+		{Field{Key: "h", Type: "multi", Entry: "Header:Value"}, []string{"A:b", "bad"}, false},
+		{Field{Key: "h", Type: "multi", Required: true}, []string{}, false},
+		{Field{Key: "t", Type: "duration"}, "500ms", true},
+		{Field{Key: "t", Type: "duration", Max: &ten}, "1m", false},
+		{Field{Key: "t", Type: "duration"}, "5 parsecs", false},
+		{Field{Key: "s", Type: "size", Min: &one}, "10M", true},
+		{Field{Key: "s", Type: "size"}, "10MB", false},
+	}
+	for _, c := range cases {
+		err := c.f.Validate(c.v)
+		if (err == nil) != c.valid {
+			t.Errorf("Validate(%s=%#v) error = %v, want valid=%v", c.f.Key, c.v, err, c.valid)
+		}
+	}
+}
+
+// TestStepped checks that stepping is symmetric, follows Step and stays
+// within Min and Max.
+func TestStepped(t *testing.T) {
+	zero, one, five, hundred, minutes, tenth := 0.0, 1.0, 5.0, 100.0, 120.0, 0.1
+	low, high := 0.5, 9.5
+	bounded := Field{Type: "int", Min: &low, Max: &high}
+	port := Field{Type: "int", Min: &one, Max: &hundred, Step: &five}
+	interval := Field{Type: "float", Min: &tenth, Step: &tenth}
+	timeout := Field{Type: "duration", Min: &zero, Max: &minutes, Step: &hundred}
+	cases := []struct {
+		f    Field
+		v    interface{}
+		n    int
+		want interface{}
+	}{
+		{port, 22, 1, 27},
+		{port, 27, -1, 22},
+		{port, 3, -1, 1},
+		{port, 98, 1, 100},
+		{port, "40", 1, 45},
+		{Field{Type: "int"}, 0, -1, -1},
+		{bounded, 1, -1, 1},
+		{bounded, 9, 1, 9},
+		{interval, 0.2, 1, 0.3},
+		{interval, 0.3, -1, 0.2},
+		{interval, 0.2, -5, 0.1},
+		{Field{Type: "float"}, 1.5, -1, 0.5},
+		{timeout, "500ms", 1, "600ms"},
+		{timeout, "600ms", -1, "500ms"},
+		{timeout, "50ms", -1, "0ms"},
+		{timeout, "1m", 1, "2m"},
+		{Field{Type: "size"}, "10M", 1, "11M"},
+		{Field{Type: "size"}, "junk", 1, "junk"},
+	}
+	for _, c := range cases {
+		if got := c.f.Stepped(c.v, c.n); got != c.want {
+			t.Errorf("%s Stepped(%v, %d) = %#v, want %#v", c.f.Type, c.v, c.n, got, c.want)
+		}
+	}
+	if got, _ := (Field{Type: "size"}).Quantity("1.5k"); got != 1536 {
+		t.Errorf("Quantity(1.5k) = %v, want 1536", got)
+	}
+}
+
+// TestCheckPath covers the must rules of path fields and "~" expansion.
+func TestCheckPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	cases := []struct {
+		must, path string
+		valid      bool
+	}{
+		{"exist", file, true},
+		{"exist", missing, false},
+		{"not-exist", missing, true},
+		{"not-exist", dir, false},
+		{"dir", dir, true},
+		{"dir", file, false},
+		{"file", file, true},
+		{"file", dir, false},
+		{"file", missing, false},
+		// Without Glob a pattern is a literal name.
+		{"file", filepath.Join(dir, "*.txt"), false},
+		{"", missing, true},
+	}
+	for _, c := range cases {
+		f := Field{Key: "p", Type: "path", Must: c.must}
+		if err := f.Validate(c.path); (err == nil) != c.valid {
+			t.Errorf("must %s: Validate(%s) error = %v, want valid=%v", c.must, c.path, err, c.valid)
+		}
+	}
+	// Patterns of glob fields are left for the shell.
+	glob := Field{Key: "p", Type: "path", Must: "file", Glob: true}
+	if err := glob.Validate(filepath.Join(dir, "*.txt")); err != nil {
+		t.Errorf("glob field: Validate(*.txt) = %v", err)
+	}
+	if err := glob.Validate(missing); err == nil {
+		t.Error("glob field: missing file accepted")
+	}
+	t.Setenv("HOME", dir)
+	if got := ExpandHome("~/f"); got != file {
+		t.Errorf("ExpandHome(~/f) = %q, want %q", got, file)
+	}
+	if got := ExpandHome("~bob/f"); got != "~bob/f" {
+		t.Errorf("ExpandHome(~bob/f) = %q", got)
+	}
+}
+
+// TestMerge checks that overlays override, extend and disable actions and
+// templates by ID.
+func TestMerge(t *testing.T) {
+	base, err := Parse(strings.NewReader(`
+actions:
+  - id: a
+    title: A
+    candidates: [x, y]
+    template: {x: "x {{q}}", y: "y {{q}}"}
+    explain: {x: {-a: "All"}}
+    danger: low
+    fields: [{key: q, type: string}]
+  - id: b
+    title: B
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := Parse(strings.NewReader(`
+actions:
+  - id: a
+    title: Renamed
+    template: {y: ~, z: "z {{q}} {{n}}"}
+    explain: {x: {-b: "Brief"}}
+    templateDanger: {z: high}
+    requires: {z: {minVersion: "1.2", capabilities: [-R]}}
+    install: {z: {apt: zed}}
+    fields: [{key: q, type: string, required: true}, {key: n, type: int}]
+  - id: b
+    disabled: true
+  - id: c
+    title: C
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.Merge(overlay)
+	if len(base.Actions) != 2 || base.Actions[0].ID != "a" || base.Actions[1].ID != "c" {
+		t.Fatalf("unexpected actions after merge: %+v", base.Actions)
+	}
+	a := base.Actions[0]
+	if a.Title != "Renamed" {
+		t.Errorf("title = %q, want Renamed", a.Title)
+	}
+	if _, ok := a.Template["y"]; ok {
+		t.Errorf("template y should have been removed")
+	}
+	if strings.Join(a.Candidates, ",") != "x,z" {
+		t.Errorf("candidates = %v, want [x z]", a.Candidates)
+	}
+	if len(a.Fields) != 2 || !a.Fields[0].Required {
+		t.Errorf("fields not merged by key: %+v", a.Fields)
+	}
+	if x := a.Explain["x"]; x["-a"] != "All" || x["-b"] != "Brief" {
+		t.Errorf("explain not merged by word: %v", a.Explain)
+	}
+	if a.DangerLevel("x") != Low || a.DangerLevel("z") != High {
+		t.Errorf("danger = %v/%v, want low/high", a.DangerLevel("x"), a.DangerLevel("z"))
+	}
+	if r := a.Requires["z"]; r.MinVersion != "1.2" || len(r.Capabilities) != 1 {
+		t.Errorf("requires not merged: %+v", a.Requires)
+	}
+	if pkg, ok := a.Package("z", "apt"); !ok || pkg != "zed" {
+		t.Errorf("install not merged: %v", a.Install)
+	}
+}
+
+// TestLoadLayered checks the search path order: user drop-ins, then the
+// project file found in a parent directory.
+func TestLoadLayered(t *testing.T) {
+	tmp := t.TempDir()
+	oldSystem := SystemDir
+	SystemDir = filepath.Join(tmp, "etc")
+	t.Cleanup(func() { SystemDir = oldSystem })
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(tmp, "etc", "registry.d", "10-site.yaml"), "actions: [{id: a, title: System}]")
+	write(filepath.Join(tmp, "config", "complete-command", "registry.d", "10-user.yaml"), "actions: [{id: a, title: User}, {id: u, title: U}]")
+	write(filepath.Join(tmp, "proj", ProjectFile), "actions: [{id: u, disabled: true}]")
+	sub := filepath.Join(tmp, "proj", "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	oldWd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(oldWd) })
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := LoadLayered([]byte("actions: [{id: a, title: Default}]"))
+	if err != nil {
+		t.Fatalf("LoadLayered: %v", err)
+	}
+	if len(reg.Actions) != 1 || reg.Actions[0].Title != "User" {
+		t.Fatalf("unexpected layered registry: %+v", reg.Actions)
+	}
+}
+
+// TestValidateRegistry checks that the shipped registry is clean and that
+// problems in an overlay are reported with their positions.
+func TestValidateRegistry(t *testing.T) {
+	data, err := os.ReadFile("../../registry.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewValidator(nil)
+	if ps := v.Validate("registry.yaml", data); len(ps) != 0 {
+		t.Fatalf("built-in registry has problems: %v", ps)
+	}
+	overlay := `actions:
+  - id: search/files
+    template:
+      rg: "rg {{query}} {{nope}}"
+      grep: "grep {{query | upper}}"
+    explain: {ack: {-x: "Unknown"}}
+    fields:
+      - {key: extra, type: bool, showIf: tool=ack}
+      - {key: other, type: strng}
+      - {key: out, type: string, must: dir, glob: true}
+      - {key: dest, type: path, must: there}
+      - {key: n, type: int, step: 0.5}
+      - {key: m, type: float, step: ~}
+    danger: risky
+    templateDanger: {ack: high}
+    requires: {grep: {minVersion: v3, caps: [-R]}}
+    install: {grep: {portage: sys-apps/grep}}
+`
+	ps := v.Validate("overlay.yaml", []byte(overlay))
+	want := []string{
+		`overlay.yaml:4:11: template "rg" references unknown field "nope"`,
+		`overlay.yaml:5:29: template "grep": unknown filter "upper"`,
+		`overlay.yaml:6:15: explain names unknown tool "ack"`,
+		`overlay.yaml:8:42: field "extra" showIf names unknown tool "ack"`,
+		`overlay.yaml:9:28: field "other" has unknown type "strng" (want one of string, path, bool, int, float, enum, multi, duration, size)`,
+		`overlay.yaml:10:40: field "out" has must but is not of type path`,
+		`overlay.yaml:10:51: field "out" has glob but is not of type path`,
+		`overlay.yaml:11:39: field "dest" has unknown must rule "there" (want one of exist, not-exist, dir, file)`,
+		`overlay.yaml:12:35: field "n" has step that is not a whole number`,
+		`overlay.yaml:13:37: field "m" step must be a number`,
+		`overlay.yaml:14:13: unknown danger level "risky" (want one of none, low, medium, high)`,
+		`overlay.yaml:15:22: templateDanger names unknown tool "ack"`,
+		`overlay.yaml:16:35: minVersion "v3" is not a dotted version number`,
+		`overlay.yaml:16:39: unknown requirement attribute "caps"`,
+		`overlay.yaml:17:22: unknown package manager "portage" (want one of apt, dnf, pacman, yum, zypper, brew)`,
+	}
+	if len(ps) != len(want) {
+		t.Fatalf("got problems %v, want %v", ps, want)
+	}
+	for i := range want {
+		if ps[i].String() != want[i] {
+			t.Errorf("problem %d = %s, want %s", i, ps[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// durationUnits maps the suffixes of duration values to seconds.  A value
+// without a suffix is in seconds.
+var durationUnits = map[string]float64{"": 1, "ms": 0.001, "s": 1, "m": 60, "h": 3600, "d": 86400}
+
+// sizeUnits maps the suffixes of size values, in lower case, to bytes.  A
+// value without a suffix is in bytes.
+var sizeUnits = map[string]float64{"": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40}
+
+// quantityRE splits a duration or size into its number and unit.
+var quantityRE = regexp.MustCompile(`^(\d+(?:\.\d+)?)([A-Za-z]*)$`)
+
+// splitQuantity returns the number and unit of a duration or size value.
+func splitQuantity(s string) (float64, string, bool) {
+	m := quantityRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, "", false
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	return n, m[2], err == nil
+}
+
+// unitFactor returns what one unit of a duration or size field is worth in
+// seconds or bytes.
+func (f Field) unitFactor(unit string) (float64, bool) {
+	if f.Type == "size" {
+		unit = strings.ToLower(unit)
+		n, ok := sizeUnits[unit]
+		return n, ok
+	}
+	n, ok := durationUnits[unit]
+	return n, ok
+}
+
+// Quantity returns the value of a duration field in seconds or of a size
+// field in bytes.  Durations are a number followed by ms, s, m, h or d, such
+// as "500ms"; sizes are a number followed by K, M, G or T, such as "10M",
+// counted in powers of 1024.  A number alone is in seconds or bytes.
+func (f Field) Quantity(s string) (float64, error) {
+	n, unit, ok := splitQuantity(s)
+	factor, known := f.unitFactor(unit)
+	if !ok || !known {
+		if f.Type == "size" {
+			return 0, fmt.Errorf("%s must be a size such as 512K or 10M", f.name())
+		}
+		return 0, fmt.Errorf("%s must be a duration such as 500ms or 30s", f.name())
+	}
+	return n * factor, nil
+}
+
+// StepSize returns the amount the field's value changes by in one step: Step
+// when set and 1 otherwise.  Durations and sizes step in the unit their
+// value is written in.
+func (f Field) StepSize() float64 {
+	if f.Step != nil && *f.Step > 0 {
+		return *f.Step
+	}
+	return 1
+}
+
+// Stepped returns the value v of an int, float, duration or size field moved
+// by n steps and kept within Min and Max.  Values that do not parse are
+// returned unchanged.
+func (f Field) Stepped(v interface{}, n int) interface{} {
+	delta := float64(n) * f.StepSize()
+	switch f.Type {
+	case "int":
+		i, err := toFloat(v, true)
+		if err != nil {
+			return v
+		}
+		// Round before clamping, to whole bounds, so fractional bounds
+		// cannot push the value outside them.
+		n := math.Round(i + delta)
+		if f.Min != nil && n < math.Ceil(*f.Min) {
+			n = math.Ceil(*f.Min)
+		}
+		if f.Max != nil && n > math.Floor(*f.Max) {
+			n = math.Floor(*f.Max)
+		}
+		return int(n)
+	case "float":
+		x, err := toFloat(v, false)
+		if err != nil {
+			return v
+		}
+		// Round away the error adding fractional steps accumulates.
+		return f.Clamp(math.Round((x+delta)*1e9) / 1e9)
+	case "duration", "size":
+		s, _ := v.(string)
+		x, unit, ok := splitQuantity(s)
+		factor, known := f.unitFactor(unit)
+		if !ok || !known {
+			return v
+		}
+		x = f.Clamp(math.Max(0, math.Round((x+delta)*1e9)/1e9)*factor) / factor
+		return strconv.FormatFloat(math.Round(x*1e9)/1e9, 'f', -1, 64) + unit
+	}
+	return v
+}
@@ -0,0 +1,502 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/cond"
+	"github.com/BlackOrder/complete-command/internal/tmpl"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FieldTypes lists the supported field types.
+var FieldTypes = []string{"string", "path", "bool", "int", "float", "enum", "multi", "duration", "size"}
+
+var (
+	registryKeys = []string{"actions"}
+	actionKeys   = []string{"id", "title", "synonyms", "candidates", "template", "fields", "explain", "danger", "templateDanger", "requires", "install", "disabled"}
+	requireKeys  = []string{"minVersion", "capabilities"}
+	fieldKeys    = []string{"key", "type", "label", "placeholder", "default", "choices", "required", "min", "max", "showIf", "entry", "help", "remember", "must", "glob", "step"}
+)
+
+// versionRe matches the version numbers requirements may name.
+var versionRe = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// Problem is a single validation finding with its position in a file.
+type Problem struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+// String formats the problem as file:line:column: message.
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Msg)
+}
+
+// Validator checks registry files.  Files are validated in layers: each file
+// is checked against the registry formed by the base and all files validated
+// before it, so an overlay may refer to fields and tools it does not define
+// itself.  Only problems in the file being validated are reported.
+type Validator struct {
+	base *Registry
+}
+
+// NewValidator returns a Validator layering files over base, which may be
+// nil for an empty registry.
+func NewValidator(base *Registry) *Validator {
+	if base == nil {
+		base = &Registry{}
+	}
+	return &Validator{base: base}
+}
+
+// Validate checks a single registry document.  name is used in reported
+// positions.  After validation the document is merged into the validator's
+// base for subsequent files.
+func (v *Validator) Validate(name string, data []byte) []Problem {
+	c := &checker{file: name}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		c.add(nil, "%s", err)
+		if line := yamlErrLine(err); line > 0 {
+			c.problems[0].Line, c.problems[0].Column = line, 1
+		}
+		return c.problems
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		c.add(root, "registry must be a mapping with an actions list")
+		return c.problems
+	}
+	var overlay Registry
+	if err := root.Decode(&overlay); err != nil {
+		c.addDecodeError(root, err)
+		return c.problems
+	}
+	c.unknownKeys(root, registryKeys, "registry")
+	actions := mapValue(root, "actions")
+	if actions == nil {
+		return c.problems
+	}
+	if actions.Kind != yaml.SequenceNode {
+		c.add(actions, "actions must be a list")
+		return c.problems
+	}
+	seen := make(map[string]bool)
+	for i, node := range actions.Content {
+		if node.Kind != yaml.MappingNode || i >= len(overlay.Actions) {
+			c.add(node, "action must be a mapping")
+			continue
+		}
+		c.action(node, overlay.Actions[i], v.base, seen)
+	}
+	v.base.Merge(&overlay)
+	sortProblems(c.problems)
+	return c.problems
+}
+
+// checker accumulates problems for one file.
+type checker struct {
+	file     string
+	problems []Problem
+}
+
+func (c *checker) add(n *yaml.Node, format string, args ...interface{}) {
+	p := Problem{File: c.file, Msg: fmt.Sprintf(format, args...)}
+	if n != nil {
+		p.Line, p.Column = n.Line, n.Column
+	}
+	c.problems = append(c.problems, p)
+}
+
+// addAt reports a problem at byte offset pos within the scalar n.  Offsets
+// map exactly onto single-line plain and quoted scalars, escape sequences
+// aside; for block scalars the offset is given in the message instead.
+func (c *checker) addAt(n *yaml.Node, pos int, format string, args ...interface{}) {
+	switch n.Style {
+	case 0, yaml.FlowStyle:
+		c.add(n, format, args...)
+		c.problems[len(c.problems)-1].Column += pos
+	case yaml.SingleQuotedStyle, yaml.DoubleQuotedStyle:
+		c.add(n, format, args...)
+		c.problems[len(c.problems)-1].Column += pos + 1
+	default:
+		c.add(n, format+" (at offset %d)", append(args, pos)...)
+	}
+}
+
+// addDecodeError reports yaml type errors, which carry their own line numbers.
+func (c *checker) addDecodeError(n *yaml.Node, err error) {
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		for _, msg := range te.Errors {
+			p := Problem{File: c.file, Msg: msg}
+			if line := yamlErrLine(errors.New(msg)); line > 0 {
+				p.Line, p.Column = line, 1
+			}
+			c.problems = append(c.problems, p)
+		}
+		return
+	}
+	c.add(n, "%s", err)
+}
+
+// unknownKeys reports mapping keys outside the allowed set, suggesting the
+// closest known key for likely typos.
+func (c *checker) unknownKeys(n *yaml.Node, allowed []string, what string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k := n.Content[i]
+		if contains(allowed, k.Value) {
+			continue
+		}
+		if s := suggest(k.Value, allowed); s != "" {
+			c.add(k, "unknown %s attribute %q (did you mean %q?)", what, k.Value, s)
+		} else {
+			c.add(k, "unknown %s attribute %q", what, k.Value)
+		}
+	}
+}
+
+// action checks one action node.  The action is resolved against base so
+// references may point at fields and tools defined by earlier layers.
+func (c *checker) action(n *yaml.Node, o Action, base *Registry, seen map[string]bool) {
+	c.unknownKeys(n, actionKeys, "action")
+	if o.ID == "" {
+		c.add(n, "action is missing an id")
+		return
+	}
+	if seen[o.ID] {
+		c.add(mapValue(n, "id"), "duplicate action id %q", o.ID)
+	}
+	seen[o.ID] = true
+	if o.Disabled {
+		return
+	}
+	// Resolve the effective action after this overlay.
+	var eff Action
+	existing := false
+	for _, a := range base.Actions {
+		if a.ID == o.ID {
+			eff = a
+			existing = true
+			break
+		}
+	}
+	eff.ID = o.ID
+	eff.Candidates = append([]string{}, eff.Candidates...)
+	eff.Template = copyTemplates(eff.Template)
+	eff.Fields = append([]Field{}, eff.Fields...)
+	eff.merge(o)
+	if !existing && o.Title == "" {
+		c.add(n, "action %q is missing a title", o.ID)
+	}
+	if !existing && len(eff.Candidates) == 0 {
+		c.add(n, "action %q has no candidates", o.ID)
+	}
+
+	if cands := mapValue(n, "candidates"); cands != nil {
+		for _, cn := range cands.Content {
+			if _, ok := eff.Template[cn.Value]; !ok {
+				c.add(cn, "candidate %q has no template", cn.Value)
+			}
+		}
+	}
+	keys := make(map[string]bool)
+	for _, f := range eff.Fields {
+		keys[f.Key] = true
+	}
+	if tn := mapValue(n, "template"); tn != nil && tn.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(tn.Content); i += 2 {
+			tool, val := tn.Content[i], tn.Content[i+1]
+			t, err := tmpl.Parse(val.Value)
+			if err != nil {
+				var te *tmpl.Error
+				if errors.As(err, &te) {
+					c.addAt(val, te.Pos, "template %q: %s", tool.Value, te.Msg)
+				} else {
+					c.add(val, "template %q: %v", tool.Value, err)
+				}
+				continue
+			}
+			for _, key := range t.Keys() {
+				if !keys[key] {
+					c.add(val, "template %q references unknown field %q", tool.Value, key)
+				}
+			}
+		}
+	}
+	if en := mapValue(n, "explain"); en != nil && en.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(en.Content); i += 2 {
+			if tool := en.Content[i]; eff.Template[tool.Value] == "" {
+				c.add(tool, "explain names unknown tool %q", tool.Value)
+			}
+		}
+	}
+	if dn := mapValue(n, "danger"); dn != nil {
+		if _, err := ParseLevel(o.Danger); err != nil {
+			c.add(dn, "%v", err)
+		}
+	}
+	if tn := mapValue(n, "templateDanger"); tn != nil && tn.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(tn.Content); i += 2 {
+			tool, val := tn.Content[i], tn.Content[i+1]
+			if eff.Template[tool.Value] == "" {
+				c.add(tool, "templateDanger names unknown tool %q", tool.Value)
+			}
+			if _, err := ParseLevel(val.Value); err != nil {
+				c.add(val, "%v", err)
+			}
+		}
+	}
+	if rn := mapValue(n, "requires"); rn != nil && rn.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(rn.Content); i += 2 {
+			tool, val := rn.Content[i], rn.Content[i+1]
+			if eff.Template[tool.Value] == "" {
+				c.add(tool, "requires names unknown tool %q", tool.Value)
+			}
+			if val.Kind != yaml.MappingNode {
+				continue
+			}
+			c.unknownKeys(val, requireKeys, "requirement")
+			if vn := mapValue(val, "minVersion"); vn != nil && !versionRe.MatchString(vn.Value) {
+				c.add(vn, "minVersion %q is not a dotted version number", vn.Value)
+			}
+		}
+	}
+	if in := mapValue(n, "install"); in != nil && in.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(in.Content); i += 2 {
+			tool, pkgs := in.Content[i], in.Content[i+1]
+			if eff.Template[tool.Value] == "" {
+				c.add(tool, "install names unknown tool %q", tool.Value)
+			}
+			for j := 0; pkgs.Kind == yaml.MappingNode && j+1 < len(pkgs.Content); j += 2 {
+				if pm := pkgs.Content[j]; !contains(PackageManagers, pm.Value) {
+					c.add(pm, "unknown package manager %q (want one of %s)", pm.Value, strings.Join(PackageManagers, ", "))
+				}
+			}
+		}
+	}
+	if fn := mapValue(n, "fields"); fn != nil && fn.Kind == yaml.SequenceNode {
+		fieldSeen := make(map[string]bool)
+		for i, node := range fn.Content {
+			if node.Kind != yaml.MappingNode || i >= len(o.Fields) {
+				c.add(node, "field must be a mapping")
+				continue
+			}
+			c.field(node, o.Fields[i], eff, keys, fieldSeen)
+		}
+	}
+}
+
+// field checks one field node of the effective action eff.
+func (c *checker) field(n *yaml.Node, f Field, eff Action, keys, seen map[string]bool) {
+	c.unknownKeys(n, fieldKeys, "field")
+	if f.Key == "" {
+		c.add(n, "field is missing a key")
+		return
+	}
+	if seen[f.Key] {
+		c.add(mapValue(n, "key"), "duplicate field key %q", f.Key)
+	}
+	seen[f.Key] = true
+	typeNode := mapValue(n, "type")
+	switch {
+	case typeNode == nil:
+		c.add(n, "field %q is missing a type", f.Key)
+	case !contains(FieldTypes, f.Type):
+		c.add(typeNode, "field %q has unknown type %q (want one of %s)", f.Key, f.Type, strings.Join(FieldTypes, ", "))
+	}
+	if f.Type == "enum" && len(f.Choices) == 0 {
+		c.add(n, "enum field %q has no choices", f.Key)
+	}
+	if sn := mapValue(n, "step"); sn != nil {
+		switch {
+		case !isNumeric(f.Type):
+			c.add(sn, "field %q has step but is not numeric", f.Key)
+		case f.Step == nil:
+			c.add(sn, "field %q step must be a number", f.Key)
+		case *f.Step <= 0:
+			c.add(sn, "field %q has step that is not positive", f.Key)
+		case f.Type == "int" && *f.Step != float64(int(*f.Step)):
+			c.add(sn, "field %q has step that is not a whole number", f.Key)
+		}
+	}
+	if gn := mapValue(n, "glob"); gn != nil && f.Type != "path" {
+		c.add(gn, "field %q has glob but is not of type path", f.Key)
+	}
+	if mn := mapValue(n, "must"); mn != nil {
+		switch {
+		case f.Type != "path":
+			c.add(mn, "field %q has must but is not of type path", f.Key)
+		case !contains(PathRules, f.Must):
+			c.add(mn, "field %q has unknown must rule %q (want one of %s)", f.Key, f.Must, strings.Join(PathRules, ", "))
+		}
+	}
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		c.add(mapValue(n, "min"), "field %q has min greater than max", f.Key)
+	}
+	if dn := mapValue(n, "default"); dn != nil {
+		c.defaultValue(dn, f)
+	}
+	sn := mapValue(n, "showIf")
+	if sn == nil {
+		return
+	}
+	expr, err := cond.Parse(f.ShowIf)
+	if err != nil {
+		var ce *cond.Error
+		if errors.As(err, &ce) {
+			c.addAt(sn, ce.Pos, "field %q showIf: %s", f.Key, ce.Msg)
+		} else {
+			c.add(sn, "field %q showIf: %v", f.Key, err)
+		}
+		return
+	}
+	for _, id := range expr.Idents() {
+		if id != "tool" && !keys[id] {
+			c.add(sn, "field %q showIf names unknown field %q", f.Key, id)
+		}
+	}
+	for _, cmp := range expr.Comparisons() {
+		if cmp.Ident == "tool" && !keys["tool"] && !contains(eff.Candidates, cmp.Value) {
+			c.add(sn, "field %q showIf names unknown tool %q", f.Key, cmp.Value)
+		}
+	}
+}
+
+// defaultValue checks that a default matches the field type.
+func (c *checker) defaultValue(n *yaml.Node, f Field) {
+	ok := true
+	switch f.Type {
+	case "bool":
+		_, ok = f.Default.(bool)
+	case "int":
+		_, ok = f.Default.(int)
+	case "float":
+		switch f.Default.(type) {
+		case int, float64:
+		default:
+			ok = false
+		}
+	case "enum":
+		s, isStr := f.Default.(string)
+		ok = isStr && contains(f.Choices, s)
+	case "multi":
+		switch f.Default.(type) {
+		case string, []interface{}:
+		default:
+			ok = false
+		}
+	case "string", "path":
+		_, ok = f.Default.(string)
+	case "duration", "size":
+		switch f.Default.(type) {
+		case string, int, float64:
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		c.add(n, "field %q has default %s that does not match type %s", f.Key, strconv.Quote(n.Value), f.Type)
+		return
+	}
+	if isNumeric(f.Type) {
+		if err := f.Validate(f.DefaultValue()); err != nil {
+			c.add(n, "field %q default: %v", f.Key, err)
+		}
+	}
+}
+
+// mapValue returns the value node for key in mapping n, or nil.
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+var lineRE = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrLine extracts the line number from a yaml error message.
+func yamlErrLine(err error) int {
+	m := lineRE.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+// suggest returns the allowed key closest to s when it is a likely typo.
+func suggest(s string, allowed []string) string {
+	best, bestDist := "", 3
+	for _, a := range allowed {
+		if d := editDistance(strings.ToLower(s), strings.ToLower(a)); d < bestDist {
+			best, bestDist = a, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func copyTemplates(t map[string]string) map[string]string {
+	out := make(map[string]string, len(t))
+	for k, v := range t {
+		out[k] = v
+	}
+	return out
+}
+
+// sortProblems orders the problems of one file by position.
+func sortProblems(ps []Problem) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Line != ps[j].Line {
+			return ps[i].Line < ps[j].Line
+		}
+		return ps[i].Column < ps[j].Column
+	})
+}
+
+// isNumeric reports whether fields of type t hold a number, possibly with a
+// unit.
+func isNumeric(t string) bool {
+	return t == "int" || t == "float" || t == "duration" || t == "size"
+}
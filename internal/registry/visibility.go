@@ -0,0 +1,68 @@
+package registry
+
+import "github.com/BlackOrder/complete-command/internal/cond"
+
+// Visible evaluates every field's showIf condition for the given tool and
+// field values and reports which fields are shown.  Fields hidden by their
+// condition do not contribute their values to other conditions, so the
+// evaluation is repeated until the visible set is stable.  A showIf that
+// fails to parse leaves its field visible.
+func (a Action) Visible(tool string, values map[string]interface{}) map[string]bool {
+	exprs := make([]cond.Expr, len(a.Fields))
+	for i, f := range a.Fields {
+		if e, err := cond.Parse(f.ShowIf); err == nil {
+			exprs[i] = e
+		}
+	}
+	visible := make(map[string]bool, len(a.Fields))
+	for _, f := range a.Fields {
+		visible[f.Key] = true
+	}
+	for range a.Fields {
+		env := cond.Env{Tool: tool, Values: make(map[string]interface{}, len(values))}
+		for k, v := range values {
+			if shown, known := visible[k]; !known || shown {
+				env.Values[k] = v
+			}
+		}
+		changed := false
+		for i, f := range a.Fields {
+			shown := exprs[i] == nil || exprs[i].Eval(env)
+			if visible[f.Key] != shown {
+				visible[f.Key] = shown
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return visible
+}
+
+// Validate checks the values of all visible fields for the given tool and
+// returns validation messages keyed by field key.  An empty map means the
+// values can be rendered.
+func (a Action) Validate(tool string, values map[string]interface{}) map[string]string {
+	errs := make(map[string]string)
+	visible := a.Visible(tool, values)
+	for _, f := range a.Fields {
+		if !visible[f.Key] {
+			continue
+		}
+		if err := f.Validate(values[f.Key]); err != nil {
+			errs[f.Key] = err.Error()
+		}
+	}
+	return errs
+}
+
+// Field returns the field with the given key.
+func (a Action) Field(key string) (Field, bool) {
+	for _, f := range a.Fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
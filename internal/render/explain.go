@@ -0,0 +1,85 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+	"github.com/BlackOrder/complete-command/internal/tmpl"
+)
+
+// Token is a word of a rendered command with what it means.
+type Token struct {
+	// Text is the word as written in the command, quotes included.
+	Text string `json:"text"`
+	// Fields lists the keys of the fields that produced the word, none
+	// for words that are always part of the template.
+	Fields []string `json:"fields,omitempty"`
+	Help   string   `json:"help,omitempty"`
+}
+
+// commonHelp explains words that are not specific to any action.
+var commonHelp = map[string]string{
+	"sudo": "Run the command as the superuser",
+}
+
+// Explain renders act for tool like Command and returns the command broken
+// into its words, each with the field that produced it and a help text.  The help of
+// a word comes from the action's explain entries for the tool, then from the
+// help or label of its field; the command name falls back to the action's
+// title.
+func Explain(act registry.Action, tool string, values map[string]interface{}, sh shellquote.Shell) (string, []Token, error) {
+	t, err := tmpl.Parse(act.Template[tool])
+	if err != nil {
+		return "", nil, err
+	}
+	cmd, spans := t.ExecuteSpans(shown(act, tool, values), sh)
+	fields := make(map[string]registry.Field, len(act.Fields))
+	for _, f := range act.Fields {
+		fields[f.Key] = f
+	}
+	var tokens []Token
+	named := false
+	for _, w := range shellquote.Words(cmd) {
+		tok := Token{Text: cmd[w.Start:w.End]}
+		var helps []string
+		for _, sp := range spans {
+			if sp.Field == "" || sp.End <= w.Start || sp.Start >= w.End || contains(tok.Fields, sp.Field) {
+				continue
+			}
+			tok.Fields = append(tok.Fields, sp.Field)
+			if f := fields[sp.Field]; f.Help != "" {
+				helps = append(helps, f.Help)
+			} else if f.Label != "" {
+				helps = append(helps, f.Label)
+			}
+		}
+		words := act.Explain[tool]
+		switch {
+		case words[tok.Text] != "":
+			tok.Help = words[tok.Text]
+		case words[w.Text] != "":
+			tok.Help = words[w.Text]
+		case len(helps) > 0:
+			tok.Help = strings.Join(helps, "; ")
+		case commonHelp[w.Text] != "":
+			tok.Help = commonHelp[w.Text]
+		case !named && len(tok.Fields) == 0:
+			tok.Help = act.Title
+		}
+		if w.Text != "sudo" {
+			named = true
+		}
+		tokens = append(tokens, tok)
+	}
+	return cmd, tokens, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
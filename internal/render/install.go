@@ -0,0 +1,33 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/BlackOrder/complete-command/internal/detect"
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// PackageManager returns the host's package manager: the first of
+// registry.PackageManagers that is installed, found the way Tools finds the
+// candidates of pkg/search.  It returns "" when there is none.
+func PackageManager() string {
+	for _, pm := range registry.PackageManagers {
+		if detect.Has(pm) {
+			return pm
+		}
+	}
+	return ""
+}
+
+// InstallCommand returns the command installing tool for act with the
+// package manager pm, quoted for sh.  It reports false when the registry
+// names no package for the tool and package manager.
+func InstallCommand(act registry.Action, tool, pm string, sh shellquote.Shell) (string, bool) {
+	pkg, ok := act.Package(tool, pm)
+	format, known := registry.InstallCommands[pm]
+	if !ok || !known {
+		return "", false
+	}
+	return fmt.Sprintf(format, shellquote.Quote(sh, pkg)), true
+}
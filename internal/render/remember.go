@@ -0,0 +1,30 @@
+package render
+
+import (
+	"github.com/BlackOrder/complete-command/internal/config"
+	"github.com/BlackOrder/complete-command/internal/registry"
+)
+
+// Remembered returns the values cfg holds for the fields of act marked
+// remember, keyed by field key.  Pass the result to Prefill to convert the
+// values to their field types.
+func Remembered(act registry.Action, cfg *config.Config) map[string]interface{} {
+	saved := cfg.RememberedValues(act.ID)
+	values := make(map[string]interface{})
+	for _, f := range act.Fields {
+		if v, ok := saved[f.Key]; ok && f.Remember {
+			values[f.Key] = v
+		}
+	}
+	return values
+}
+
+// Remember stores in cfg the values of the fields of act marked remember.
+// Fields missing from values are left as they were.
+func Remember(act registry.Action, cfg *config.Config, values map[string]interface{}) {
+	for _, f := range act.Fields {
+		if v, ok := values[f.Key]; ok && f.Remember {
+			cfg.Remember(act.ID, f.Key, v)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package render builds commands from registry actions without a user
+// interface.  The interactive action form and the render subcommand both go
+// through this package, so a given action, tool and set of values always
+// produce the same command.
+package render
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BlackOrder/complete-command/internal/config"
+	"github.com/BlackOrder/complete-command/internal/detect"
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+	"github.com/BlackOrder/complete-command/internal/tmpl"
+)
+
+// Tools returns the candidate tools of an action in the order they should be
+// offered: installed candidates that meet the action's requirements first
+// (all candidates when none does), with the user's preferred tool moved to
+// the front.
+func Tools(act registry.Action, cfg *config.Config) []string {
+	var available []string
+	for _, c := range act.Candidates {
+		if r := act.Requires[c]; detect.Capable(c, r.MinVersion, r.Capabilities) {
+			available = append(available, c)
+		}
+	}
+	if len(available) == 0 {
+		// If none are found, fallback to listing all candidates anyway; the
+		// resulting command may still be valid if user has them installed.
+		available = append(available, act.Candidates...)
+	}
+	if cfg != nil && act.ID != "" {
+		if pref, ok := cfg.PreferredTool(act.ID); ok {
+			for i, t := range available {
+				if t == pref {
+					available[0], available[i] = available[i], available[0]
+					break
+				}
+			}
+		}
+	}
+	return available
+}
+
+// Usable reports whether any candidate of act is installed and meets the
+// action's requirements.
+func Usable(act registry.Action) bool {
+	for _, c := range act.Candidates {
+		if r := act.Requires[c]; detect.Capable(c, r.MinVersion, r.Capabilities) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect checks the candidates of every action in reg concurrently and
+// reports which actions are usable, keyed by action ID.  The detector caches
+// what it finds, so Tools and Usable answer from the cache afterwards.
+func Detect(reg *registry.Registry) map[string]bool {
+	var bins []string
+	for _, act := range reg.Actions {
+		bins = append(bins, act.Candidates...)
+	}
+	detect.Available(bins)
+	// Probe the tools with requirements in parallel as well; probing runs
+	// the tools, which is slower than a lookup.
+	var wg sync.WaitGroup
+	for _, act := range reg.Actions {
+		for tool, r := range act.Requires {
+			wg.Add(1)
+			go func(tool string, r registry.Requirement) {
+				defer wg.Done()
+				detect.Capable(tool, r.MinVersion, r.Capabilities)
+			}(tool, r)
+		}
+	}
+	wg.Wait()
+	usable := make(map[string]bool, len(reg.Actions))
+	for _, act := range reg.Actions {
+		usable[act.ID] = Usable(act)
+	}
+	return usable
+}
+
+// Defaults returns the default value of every field of act.
+func Defaults(act registry.Action) map[string]interface{} {
+	values := make(map[string]interface{}, len(act.Fields))
+	for _, f := range act.Fields {
+		values[f.Key] = f.DefaultValue()
+	}
+	return values
+}
+
+// Prefill returns the defaults of act overlaid with saved, which may come
+// from a loosely typed source such as the history file.  Saved values are
+// converted to their field types; unknown keys and values that no longer fit
+// their field are dropped in favour of the default.
+func Prefill(act registry.Action, saved map[string]interface{}) map[string]interface{} {
+	values := Defaults(act)
+	for k, v := range saved {
+		f, ok := act.Field(k)
+		if !ok {
+			continue
+		}
+		if cv, err := f.Coerce(v); err == nil {
+			values[k] = cv
+		}
+	}
+	return values
+}
+
+// Set parses raw according to the type of the field named key and stores it
+// in values.  Entries for multi fields are appended, replacing the defaults
+// on the first call for that key; seen tracks which keys were already set.
+func Set(act registry.Action, values map[string]interface{}, seen map[string]bool, key, raw string) error {
+	f, ok := act.Field(key)
+	if !ok {
+		return fmt.Errorf("unknown field %q for action %s", key, act.ID)
+	}
+	v, err := f.ParseValue(raw)
+	if err != nil {
+		return err
+	}
+	if f.Type == "multi" {
+		var entries []string
+		if seen[key] {
+			entries, _ = values[key].([]string)
+		}
+		values[key] = append(entries, v.(string))
+	} else {
+		values[key] = v
+	}
+	seen[key] = true
+	return nil
+}
+
+// ParseAssignment splits a "key=value" argument.
+func ParseAssignment(arg string) (key, value string, err error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid assignment %q, expected key=value", arg)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+// Command renders act's template for tool.  Values of fields hidden by their
+// showIf condition are left out, so their actions render empty, and paths
+// starting with "~" are expanded to the home directory.  An error is
+// returned when the template does not parse.
+func Command(act registry.Action, tool string, values map[string]interface{}, sh shellquote.Shell) (string, error) {
+	return tmpl.Render(act.Template[tool], shown(act, tool, values), sh)
+}
+
+// shown returns the values of the fields visible for tool, with a leading
+// "~" of path values expanded, since the shell does not expand it once the
+// value is quoted.  Patterns of path fields with Glob set are passed on as
+// tmpl.Glob, so the shell expands them.
+func shown(act registry.Action, tool string, values map[string]interface{}) map[string]interface{} {
+	visible := act.Visible(tool, values)
+	shown := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if visible[k] {
+			shown[k] = v
+		}
+	}
+	for _, f := range act.Fields {
+		if s, ok := shown[f.Key].(string); ok && f.Type == "path" {
+			s = registry.ExpandHome(s)
+			shown[f.Key] = s
+			if f.Glob && registry.IsGlob(s) {
+				shown[f.Key] = tmpl.Glob(s)
+			}
+		}
+	}
+	return shown
+}
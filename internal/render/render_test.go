@@ -0,0 +1,215 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BlackOrder/complete-command/internal/config"
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+var httpAction = registry.Action{
+	ID:         "net/http",
+	Candidates: []string{"curl"},
+	Template: map[string]string{
+		"curl": `curl -sS {{method | fmt "-X %s"}} {{header | fmt "-H %s"}} {{data | fmt "--data %s"}} {{url}}`,
+	},
+	Fields: []registry.Field{
+		{Key: "url", Type: "string", Required: true},
+		{Key: "method", Type: "enum", Choices: []string{"GET", "POST"}, Default: "GET"},
+		{Key: "header", Type: "multi", Entry: "Header:Value"},
+		{Key: "data", Type: "string", ShowIf: "method!=GET"},
+	},
+}
+
+func TestCommand(t *testing.T) {
+	values := Defaults(httpAction)
+	seen := make(map[string]bool)
+	for _, a := range []string{"url=https://example.com", "header=Accept:*/*", "header=X-A:1", "data=x=1"} {
+		key, val, err := ParseAssignment(a)
+		if err != nil {
+			t.Fatalf("ParseAssignment(%q): %v", a, err)
+		}
+		if err := Set(httpAction, values, seen, key, val); err != nil {
+			t.Fatalf("Set(%q): %v", a, err)
+		}
+	}
+	// data is hidden while the method is GET.
+	got, err := Command(httpAction, "curl", values, shellquote.Sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "curl -sS -X GET -H 'Accept:*/*' -H X-A:1 https://example.com"
+	if got != want {
+		t.Fatalf("Command() = %q, want %q", got, want)
+	}
+	if err := Set(httpAction, values, seen, "method", "post"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = Command(httpAction, "curl", values, shellquote.Sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "curl -sS -X POST -H 'Accept:*/*' -H X-A:1 --data x=1 https://example.com"
+	if got != want {
+		t.Fatalf("Command() = %q, want %q", got, want)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	values := Defaults(httpAction)
+	seen := make(map[string]bool)
+	if err := Set(httpAction, values, seen, "nope", "x"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if err := Set(httpAction, values, seen, "method", "TRACE"); err == nil {
+		t.Error("expected error for invalid enum choice")
+	}
+	if err := Set(httpAction, values, seen, "header", "no-separator"); err == nil {
+		t.Error("expected error for malformed multi entry")
+	}
+	if _, _, err := ParseAssignment("novalue"); err == nil {
+		t.Error("expected error for assignment without '='")
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	// Values as decoded from JSON: lists are []interface{}.
+	saved := map[string]interface{}{
+		"url":    "https://example.com",
+		"method": "post",
+		"header": []interface{}{"Accept:*/*"},
+		"data":   42.0,
+		"gone":   "x",
+	}
+	values := Prefill(httpAction, saved)
+	want := map[string]interface{}{
+		"url":    "https://example.com",
+		"method": "POST",
+		"header": []string{"Accept:*/*"},
+		"data":   "",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("Prefill() = %#v, want %#v", values, want)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	act := registry.Action{
+		ID:    "search/files",
+		Title: "Search in files",
+		Template: map[string]string{
+			"rg": `sudo rg {{if hidden}}-uu{{end}} {{ctx | fmt "-C %d"}} {{query}} {{dir}}`,
+		},
+		Explain: map[string]map[string]string{
+			"rg": {"-uu": "Search hidden and ignored files", "-C": "Show lines of context"},
+		},
+		Fields: []registry.Field{
+			{Key: "query", Type: "string", Help: "Pattern to search for"},
+			{Key: "dir", Type: "path", Label: "Directory"},
+			{Key: "hidden", Type: "bool"},
+			{Key: "ctx", Type: "int", Help: "Number of context lines"},
+		},
+	}
+	values := map[string]interface{}{"query": "a b", "dir": ".", "hidden": true, "ctx": 3}
+	cmd, tokens, err := Explain(act, "rg", values, shellquote.Sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "sudo rg -uu -C 3 'a b' ." {
+		t.Fatalf("command = %q", cmd)
+	}
+	want := []Token{
+		{Text: "sudo", Help: "Run the command as the superuser"},
+		{Text: "rg", Help: "Search in files"},
+		{Text: "-uu", Fields: []string{"hidden"}, Help: "Search hidden and ignored files"},
+		{Text: "-C", Fields: []string{"ctx"}, Help: "Show lines of context"},
+		{Text: "3", Fields: []string{"ctx"}, Help: "Number of context lines"},
+		{Text: "'a b'", Fields: []string{"query"}, Help: "Pattern to search for"},
+		{Text: ".", Fields: []string{"dir"}, Help: "Directory"},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("tokens = %+v\nwant %+v", tokens, want)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	reg := &registry.Registry{Actions: []registry.Action{
+		{ID: "shell", Candidates: []string{"unlikely_nonexistent_command_name", "sh"}},
+		{ID: "missing", Candidates: []string{"unlikely_nonexistent_command_name"}},
+		{ID: "incapable", Candidates: []string{"sh"}, Requires: map[string]registry.Requirement{
+			"sh": {Capabilities: []string{"--unlikely-nonexistent-option"}},
+		}},
+	}}
+	want := map[string]bool{"shell": true, "missing": false, "incapable": false}
+	if got := Detect(reg); !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect = %v, want %v", got, want)
+	}
+}
+
+func TestInstallCommand(t *testing.T) {
+	act := registry.Action{Install: map[string]map[string]string{"rg": {"apt": "ripgrep", "brew": "ripgrep"}}}
+	if got, ok := InstallCommand(act, "rg", "apt", shellquote.Sh); !ok || got != "sudo apt install ripgrep" {
+		t.Errorf("apt: %q, %v", got, ok)
+	}
+	if got, ok := InstallCommand(act, "rg", "brew", shellquote.Sh); !ok || got != "brew install ripgrep" {
+		t.Errorf("brew: %q, %v", got, ok)
+	}
+	if _, ok := InstallCommand(act, "rg", "dnf", shellquote.Sh); ok {
+		t.Error("dnf: got a command without a package")
+	}
+}
+
+func TestRemember(t *testing.T) {
+	act := registry.Action{ID: "ssh/login", Fields: []registry.Field{
+		{Key: "host", Type: "string", Remember: true},
+		{Key: "port", Type: "int", Default: 22, Remember: true},
+		{Key: "user", Type: "string"},
+	}}
+	cfg := &config.Config{}
+	Remember(act, cfg, map[string]interface{}{"host": "example.com", "user": "bob"})
+	got := Prefill(act, Remembered(act, cfg))
+	want := map[string]interface{}{"host": "example.com", "port": 22, "user": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("remembered values = %v, want %v", got, want)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	act := registry.Action{
+		Template: map[string]string{"ls": "ls {{dir}} {{pattern}}"},
+		Fields: []registry.Field{
+			{Key: "dir", Type: "path"},
+			{Key: "pattern", Type: "string"},
+		},
+	}
+	got, err := Command(act, "ls", map[string]interface{}{"dir": "~/my files", "pattern": "~"}, shellquote.Sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ls '/home/me/my files' '~'"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+}
+
+// TestGlob checks that patterns are left for the shell only in path fields
+// with glob set.
+func TestGlob(t *testing.T) {
+	act := registry.Action{
+		Template: map[string]string{"du": "du -sh {{path}} {{literal}}"},
+		Fields: []registry.Field{
+			{Key: "path", Type: "path", Glob: true},
+			{Key: "literal", Type: "path"},
+		},
+	}
+	got, err := Command(act, "du", map[string]interface{}{"path": "my logs/*.log", "literal": "*.go"}, shellquote.Sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "du -sh 'my logs/'*.log '*.go'"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+}
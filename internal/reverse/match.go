@@ -0,0 +1,345 @@
+package reverse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+)
+
+// bind records a value read from the line for a field.
+type bind struct {
+	key string
+	val string
+}
+
+// matcher matches the words of a line against the elements of a variant by
+// backtracking.  Each step calls a continuation with the words left over, so
+// a choice is undone as soon as the rest of the line cannot be matched.
+type matcher struct {
+	fields  map[string]registry.Field
+	binds   []bind
+	skipped []string
+	// budget is the number of words that may still be skipped or dropped.
+	budget int
+	// options holds the groups starting with a literal word, such as "-p
+	// {{port}}", reached so far.  Options are often given in another order
+	// than the template's, so they are matched wherever they appear after
+	// their place in the template.
+	options []*option
+	// depth counts the groups being matched; options are only recognised
+	// outside of them.
+	depth int
+	lits  int
+	slots int
+	// dropped counts the literal template words left out of the line.
+	dropped int
+}
+
+type option struct {
+	e    elem
+	used bool
+}
+
+// mark is a point to return to when a choice fails.
+type mark struct {
+	binds, skipped, options, budget, lits, slots, dropped int
+}
+
+func (m *matcher) mark() mark {
+	return mark{len(m.binds), len(m.skipped), len(m.options), m.budget, m.lits, m.slots, m.dropped}
+}
+
+func (m *matcher) reset(k mark) {
+	m.binds, m.skipped, m.options = m.binds[:k.binds], m.skipped[:k.skipped], m.options[:k.options]
+	m.budget, m.lits, m.slots, m.dropped = k.budget, k.lits, k.slots, k.dropped
+}
+
+// maxReadings bounds the number of readings of a line collected per variant.
+const maxReadings = 32
+
+// match reads words as the variant and returns the possible readings.  The
+// first word must match the template's leading literal word, the command
+// name.  Only the readings that skip or drop the fewest words are returned.
+func (v *variant) match(words []string, fields map[string]registry.Field) []Match {
+	if len(v.elems) == 0 || v.elems[0].re == nil || !v.elems[0].literal {
+		return nil
+	}
+	for budget := 0; budget <= maxSkipped; budget++ {
+		m := &matcher{fields: fields, budget: budget}
+		var ms []Match
+		var done func(ws []string) bool
+		done = func(ws []string) bool {
+			if len(ws) > 0 {
+				return m.skip(nil, ws, done)
+			}
+			if values, ok := m.values(v); ok {
+				ms = append(ms, Match{
+					Values:   values,
+					Skipped:  append([]string(nil), m.skipped...),
+					literals: m.lits,
+					slots:    m.slots,
+					dropped:  m.dropped,
+				})
+			}
+			// Keep backtracking for other readings.
+			return len(ms) >= maxReadings
+		}
+		m.word(v.elems[0], words, func(ws []string) bool { return m.seq(v.elems[1:], ws, done) })
+		if len(ms) > 0 {
+			return ms
+		}
+	}
+	return nil
+}
+
+// seq matches es against ws and calls k with the words that remain.
+func (m *matcher) seq(es []elem, ws []string, k func([]string) bool) bool {
+	if ok, found := m.option(es, ws, k); found {
+		return ok
+	}
+	if len(es) == 0 {
+		return k(ws)
+	}
+	e, rest := es[0], es[1:]
+	if e.re == nil {
+		if len(e.group) > 0 && e.group[0].literal {
+			at := m.mark()
+			m.options = append(m.options, &option{e: e})
+			if m.seq(rest, ws, k) {
+				return true
+			}
+			m.reset(at)
+			return false
+		}
+		if m.group(e, ws, func(after []string) bool {
+			if e.rep {
+				return m.seq(es, after, k)
+			}
+			return m.seq(rest, after, k)
+		}) {
+			return true
+		}
+		return m.seq(rest, ws, k)
+	}
+	if m.word(e, ws, func(after []string) bool { return m.seq(rest, after, k) }) {
+		return true
+	}
+	if e.optional() && m.seq(rest, ws, k) {
+		return true
+	}
+	if m.drop(e, rest, ws, k) {
+		return true
+	}
+	return m.skip(es, ws, k)
+}
+
+// option matches the first word against the options reached so far.  A word
+// naming an option is always read as that option; found reports whether one
+// matched.
+func (m *matcher) option(es []elem, ws []string, k func([]string) bool) (ok, found bool) {
+	if m.depth > 0 || len(ws) == 0 {
+		return false, false
+	}
+	for _, o := range m.options {
+		if o.used || !o.e.group[0].re.MatchString(ws[0]) {
+			continue
+		}
+		// A repeated option stays available once it has matched.
+		o.used = !o.e.rep
+		entered := false
+		ok := m.group(o.e, ws, func(after []string) bool {
+			entered = true
+			return m.seq(es, after, k)
+		})
+		o.used = false
+		if entered {
+			return ok, true
+		}
+	}
+	return false, false
+}
+
+// group matches one occurrence of a group element, which must consume at
+// least one word.
+func (m *matcher) group(e elem, ws []string, k func([]string) bool) bool {
+	at := m.mark()
+	if e.flag != "" {
+		m.binds = append(m.binds, bind{e.flag, "true"})
+	}
+	m.depth++
+	ok := m.seq(e.group, ws, func(after []string) bool {
+		if len(after) == len(ws) {
+			return false
+		}
+		m.depth--
+		defer func() { m.depth++ }()
+		return k(after)
+	})
+	m.depth--
+	if !ok {
+		m.reset(at)
+	}
+	return ok
+}
+
+// word matches a single word element against the first word.
+func (m *matcher) word(e elem, ws []string, k func([]string) bool) bool {
+	if len(ws) == 0 {
+		return false
+	}
+	// A word made only of a value is not taken to be an option.
+	if !e.literal && len(ws[0]) > 1 && ws[0][0] == '-' {
+		return false
+	}
+	sub := e.re.FindStringSubmatch(ws[0])
+	if sub == nil {
+		return false
+	}
+	at := m.mark()
+	for i, s := range e.slots {
+		if !m.bind(s, sub[i+1]) {
+			m.reset(at)
+			return false
+		}
+	}
+	if e.literal {
+		m.lits++
+	}
+	if k(ws[1:]) {
+		return true
+	}
+	m.reset(at)
+	return false
+}
+
+// skip leaves the first word unexplained, when the budget allows, and goes
+// on matching es.
+func (m *matcher) skip(es []elem, ws []string, k func([]string) bool) bool {
+	if len(ws) == 0 || m.budget == 0 {
+		return false
+	}
+	at := m.mark()
+	m.budget--
+	m.skipped = append(m.skipped, ws[0])
+	if m.seq(es, ws[1:], k) {
+		return true
+	}
+	m.reset(at)
+	return false
+}
+
+// drop leaves out a literal word of the template that the line does not
+// have, such as the "-lah" of "ls -lah" in "ls -l", when the budget allows,
+// and goes on matching the rest.  Words holding values and the words of
+// groups are never dropped.
+func (m *matcher) drop(e elem, rest []elem, ws []string, k func([]string) bool) bool {
+	if !e.literal || len(e.slots) > 0 || m.depth > 0 || m.budget == 0 {
+		return false
+	}
+	at := m.mark()
+	m.budget--
+	m.dropped++
+	if m.seq(rest, ws, k) {
+		return true
+	}
+	m.reset(at)
+	return false
+}
+
+// bind records the text captured for a slot.  It fails when the text is not
+// a valid value for the field or contradicts a value read earlier.
+func (m *matcher) bind(s *slot, text string) bool {
+	if s.flag {
+		text = fmt.Sprint(text != "")
+	}
+	f, known := m.fields[s.key]
+	if f.Type == "multi" {
+		entries := []string{text}
+		if s.sep != "" {
+			entries = strings.Split(text, s.sep)
+		}
+		for _, e := range entries {
+			if _, err := f.ParseValue(e); err != nil {
+				return false
+			}
+			m.binds = append(m.binds, bind{s.key, e})
+		}
+		m.slots++
+		return true
+	}
+	if known {
+		if _, err := f.ParseValue(text); err != nil {
+			return false
+		}
+	}
+	for _, b := range m.binds {
+		if b.key == s.key && b.val != text {
+			return false
+		}
+	}
+	m.binds = append(m.binds, bind{s.key, text})
+	if !s.flag {
+		m.slots++
+	}
+	return true
+}
+
+// values converts the bindings to field values and adds what the variant's
+// branches imply.  It fails when an implication contradicts the line.
+func (m *matcher) values(v *variant) (map[string]interface{}, bool) {
+	values := make(map[string]interface{})
+	for _, b := range m.binds {
+		f, known := m.fields[b.key]
+		switch {
+		case !known:
+			values[b.key] = b.val
+		case f.Type == "multi":
+			list, _ := values[b.key].([]string)
+			values[b.key] = append(list, b.val)
+		default:
+			values[b.key], _ = f.ParseValue(b.val)
+		}
+	}
+	for _, key := range v.flags {
+		if _, ok := values[key]; !ok {
+			values[key] = false
+		}
+	}
+	for key, a := range v.assume {
+		f, known := m.fields[key]
+		if !known {
+			continue
+		}
+		cur, bound := values[key]
+		switch a := a.(type) {
+		case bool:
+			switch {
+			case f.Type == "bool":
+				if bound && cur != a {
+					return nil, false
+				}
+				values[key] = a
+			case a != bound:
+				// A field is empty exactly when the line leaves its
+				// value out.
+				return nil, false
+			case !a:
+				values[key] = zero(f)
+			}
+		case string:
+			if f.Type == "multi" {
+				continue
+			}
+			want, err := f.ParseValue(a)
+			if err != nil {
+				continue
+			}
+			if bound && fmt.Sprint(cur) != fmt.Sprint(want) {
+				return nil, false
+			}
+			values[key] = want
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,412 @@
+package reverse
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/tmpl"
+)
+
+// maxVariants bounds the number of branch combinations tried per template.
+const maxVariants = 512
+
+// piece is a template fragment once conditionals have been resolved: literal
+// text, a field slot, or a group produced by a fmt filter.
+type piece struct {
+	text  string
+	slot  *slot
+	group *group
+}
+
+// slot is where a field value appears in the command.
+type slot struct {
+	key  string
+	sep  string // join separator, splitting the word into entries
+	flag bool   // a bool field, true when its group is present
+}
+
+// group is the expansion of a fmt filter, optional when the value is empty
+// and repeated for every entry of a multi field.  A group with a flag is the
+// text of {{if flag}}…{{end}} for a bool field: present when the field is set.
+type group struct {
+	pieces []piece
+	rep    bool
+	flag   string
+}
+
+// variant is a template with every conditional resolved to one branch.
+type variant struct {
+	pieces []piece
+	// assume holds what the chosen branches imply about field values, as
+	// a truthiness (bool) or an exact string value.
+	assume map[string]interface{}
+	// flags lists the bool fields set by flag groups, false when absent.
+	flags []string
+	elems []elem
+}
+
+// variants expands nodes into one variant per combination of branches.
+func variants(nodes []tmpl.Node, fields map[string]registry.Field) []*variant {
+	vs := expand(nodes, fields, []*variant{{assume: map[string]interface{}{}}})
+	for _, v := range vs {
+		v.elems = compile(v.pieces)
+		// A leading sudo is optional, like in the line.
+		if len(v.elems) > 0 && v.elems[0].re != nil && v.elems[0].re.String() == "^sudo$" {
+			v.elems = v.elems[1:]
+		}
+	}
+	return vs
+}
+
+func expand(nodes []tmpl.Node, fields map[string]registry.Field, vs []*variant) []*variant {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *tmpl.TextNode:
+			for _, v := range vs {
+				v.pieces = append(v.pieces, piece{text: n.Text})
+			}
+		case *tmpl.ActionNode:
+			p := actionPiece(n, fields)
+			for _, v := range vs {
+				v.pieces = append(v.pieces, p)
+			}
+		case *tmpl.IfNode:
+			if key, text, ok := flagGroup(n, fields); ok {
+				for _, v := range vs {
+					v.pieces = append(v.pieces, piece{group: &group{pieces: []piece{{text: text}}, flag: key}})
+					v.flags = append(v.flags, key)
+				}
+				continue
+			}
+			var next []*variant
+			for _, v := range vs {
+				for i := 0; i <= len(n.Branches); i++ {
+					w := v.clone()
+					ok := true
+					for j := 0; j < i && j < len(n.Branches); j++ {
+						ok = ok && w.imply(n.Branches[j].Src, false)
+					}
+					body := n.Else
+					if i < len(n.Branches) {
+						ok = ok && w.imply(n.Branches[i].Src, true)
+						body = n.Branches[i].Body
+					}
+					if !ok {
+						continue
+					}
+					next = append(next, expand(body, fields, []*variant{w})...)
+					if len(next) >= maxVariants {
+						break
+					}
+				}
+			}
+			vs = next
+		}
+	}
+	return vs
+}
+
+// flagGroup reports whether n is {{if key}}text{{end}} for a bool field key.
+// Such a conditional is kept as an optional group rather than expanded into
+// variants, so the flag may appear anywhere in the line.
+func flagGroup(n *tmpl.IfNode, fields map[string]registry.Field) (key, text string, ok bool) {
+	if len(n.Branches) != 1 || n.Else != nil {
+		return "", "", false
+	}
+	b := n.Branches[0]
+	key = strings.TrimSpace(b.Src)
+	if !isIdent(key) || fields[key].Type != "bool" {
+		return "", "", false
+	}
+	for _, c := range b.Body {
+		t, isText := c.(*tmpl.TextNode)
+		if !isText {
+			return "", "", false
+		}
+		text += t.Text
+	}
+	return key, text, strings.TrimSpace(text) != ""
+}
+
+func (v *variant) clone() *variant {
+	w := &variant{
+		pieces: append([]piece(nil), v.pieces...),
+		assume: make(map[string]interface{}, len(v.assume)),
+		flags:  append([]string(nil), v.flags...),
+	}
+	for k, a := range v.assume {
+		w.assume[k] = a
+	}
+	return w
+}
+
+// imply records what a condition being true or false says about the values.
+// Only simple conditions are understood: an identifier, its negation and a
+// comparison with a literal.  It reports false when the implication
+// contradicts an earlier one.
+func (v *variant) imply(src string, truth bool) bool {
+	src = strings.TrimSpace(src)
+	for _, p := range []string{"!", "not "} {
+		if rest := strings.TrimSpace(strings.TrimPrefix(src, p)); rest != src && isIdent(rest) {
+			src, truth = rest, !truth
+		}
+	}
+	if isIdent(src) {
+		return v.assumeValue(src, truth)
+	}
+	for _, op := range []string{"!=", "==", "="} {
+		key, val, ok := strings.Cut(src, op)
+		key, val = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(val), `'"`)
+		if !ok || !isIdent(key) {
+			continue
+		}
+		if (op == "!=") != truth {
+			return v.assumeValue(key, val)
+		}
+		return true
+	}
+	return true
+}
+
+func (v *variant) assumeValue(key string, a interface{}) bool {
+	if key == "tool" {
+		return true
+	}
+	if old, ok := v.assume[key]; ok && old != a {
+		return false
+	}
+	v.assume[key] = a
+	return true
+}
+
+var identRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func isIdent(s string) bool { return identRE.MatchString(s) }
+
+// actionPiece converts an action into the piece it renders as.
+func actionPiece(n *tmpl.ActionNode, fields map[string]registry.Field) piece {
+	if n.Field == "" {
+		return piece{text: n.Literal}
+	}
+	s := &slot{key: n.Field}
+	format := ""
+	for _, f := range n.Filters {
+		switch f.Name {
+		case "join":
+			if format == "" {
+				s.sep = f.Args[0]
+			}
+		case "fmt":
+			if format == "" {
+				format = f.Args[0]
+			}
+		}
+	}
+	multi := fields[n.Field].Type == "multi" && s.sep == ""
+	if format == "" {
+		if multi {
+			return piece{group: &group{pieces: []piece{{slot: s}}, rep: true}}
+		}
+		return piece{slot: s}
+	}
+	prefix, suffix := splitFormat(format)
+	return piece{group: &group{pieces: []piece{{text: prefix}, {slot: s}, {text: suffix}}, rep: multi}}
+}
+
+// splitFormat returns the text around the single verb of a fmt format.
+func splitFormat(format string) (prefix, suffix string) {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j < len(format) {
+			j++
+		}
+		unescape := strings.NewReplacer("%%", "%")
+		return unescape.Replace(format[:i]), unescape.Replace(format[j:])
+	}
+	return format, ""
+}
+
+// elem is a pattern for one or more words of the line.
+type elem struct {
+	// re matches a single word; its capture groups are the slots.
+	re    *regexp.Regexp
+	slots []*slot
+	// literal reports that the word contains template text, so matching it
+	// is evidence for the template.
+	literal bool
+	// group and rep describe an optional, possibly repeated, group of
+	// elements; re is nil for groups.  flag is the bool field set when the
+	// group is present.
+	group []elem
+	rep   bool
+	flag  string
+}
+
+// optional reports whether a word pattern may be absent: a word made only of
+// values disappears when they are empty.
+func (e elem) optional() bool { return e.re != nil && !e.literal }
+
+// atom is a unit of the flattened template: a run of word characters, a
+// word break, a slot or the bounds of a group.
+type atom struct {
+	text  string
+	space bool
+	slot  *slot
+	open  *group
+	close bool
+}
+
+// compile turns the pieces of a variant into word patterns.
+func compile(pieces []piece) []elem {
+	var quote byte
+	atoms := flatten(pieces, &quote, nil)
+	elems, _ := build(atoms)
+	return elems
+}
+
+// flatten splits pieces into atoms.  Template text is read with shell
+// quoting rules, tracked across pieces in quote: whitespace inside quotes
+// belongs to the word and the quotes themselves are dropped, just as
+// splitting the rendered line would.
+func flatten(pieces []piece, quote *byte, atoms []atom) []atom {
+	for _, p := range pieces {
+		switch {
+		case p.slot != nil:
+			atoms = append(atoms, atom{slot: p.slot})
+		case p.group != nil:
+			atoms = append(atoms, atom{open: p.group})
+			atoms = flatten(p.group.pieces, quote, atoms)
+			atoms = append(atoms, atom{close: true})
+		default:
+			var b strings.Builder
+			flush := func() {
+				if b.Len() > 0 {
+					atoms = append(atoms, atom{text: b.String()})
+					b.Reset()
+				}
+			}
+			for i := 0; i < len(p.text); i++ {
+				c := p.text[i]
+				switch {
+				case *quote != 0:
+					if c == *quote {
+						*quote = 0
+					} else {
+						b.WriteByte(c)
+					}
+				case c == '\'' || c == '"':
+					*quote = c
+				case c == '\\' && i+1 < len(p.text):
+					i++
+					b.WriteByte(p.text[i])
+				case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+					flush()
+					if n := len(atoms); n == 0 || !atoms[n-1].space {
+						atoms = append(atoms, atom{space: true})
+					}
+				default:
+					b.WriteByte(c)
+				}
+			}
+			flush()
+		}
+	}
+	return atoms
+}
+
+// build assembles atoms into elements, stopping at the close of the
+// enclosing group.  It returns the elements and the atoms consumed.
+func build(atoms []atom) ([]elem, int) {
+	var elems []elem
+	var word []atom
+	flushWord := func() {
+		if len(word) > 0 {
+			elems = append(elems, wordElem(word))
+			word = nil
+		}
+	}
+	for i := 0; i < len(atoms); i++ {
+		a := atoms[i]
+		switch {
+		case a.close:
+			flushWord()
+			return elems, i + 1
+		case a.space:
+			flushWord()
+		case a.open != nil:
+			end := i + 1 + groupLen(atoms[i+1:])
+			atEnd := end >= len(atoms) || atoms[end].space || atoms[end].close
+			if len(word) == 0 && atEnd {
+				inner, _ := build(atoms[i+1:])
+				elems = append(elems, elem{group: inner, rep: a.open.rep, flag: a.open.flag})
+			} else {
+				// A group inside a word becomes an optional part of it.
+				word = append(word, atoms[i:end]...)
+			}
+			i = end - 1
+		default:
+			word = append(word, a)
+		}
+	}
+	flushWord()
+	return elems, len(atoms)
+}
+
+// groupLen returns the number of atoms up to and including the close of the
+// group whose content starts atoms.
+func groupLen(atoms []atom) int {
+	depth := 0
+	for i, a := range atoms {
+		switch {
+		case a.open != nil:
+			depth++
+		case a.close:
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return len(atoms)
+}
+
+// wordElem compiles the atoms of one word into a regular expression.
+func wordElem(atoms []atom) elem {
+	var e elem
+	var b strings.Builder
+	b.WriteString("^")
+	for _, a := range atoms {
+		switch {
+		case a.text != "":
+			b.WriteString(regexp.QuoteMeta(a.text))
+			e.literal = true
+		case a.space:
+			b.WriteString(" ")
+		case a.slot != nil:
+			b.WriteString("(.+?)")
+			e.slots = append(e.slots, a.slot)
+		case a.open != nil && a.open.flag != "":
+			// The flag is captured; an empty capture means it is absent.
+			b.WriteString("(")
+			e.slots = append(e.slots, &slot{key: a.open.flag, flag: true})
+		case a.open != nil:
+			b.WriteString("(?:")
+		case a.close:
+			b.WriteString(")?")
+		}
+	}
+	b.WriteString("$")
+	e.re = regexp.MustCompile(b.String())
+	return e
+}
@@ -0,0 +1,214 @@
+// Package reverse recognises a command line as a registry action.  Given a
+// line such as "rg -i foo src" it finds the action and tool whose template
+// could have produced it and recovers the field values, so that a half-typed
+// or previously built command can be reopened in its form.
+//
+// Each template is expanded into variants, one per combination of its
+// conditional branches.  A variant is a sequence of word patterns: literal
+// words, words holding field values and optional or repeated groups produced
+// by fmt filters.  The words of the line are matched against every variant,
+// tolerating a few words the template cannot account for, and the best
+// matches are returned.
+package reverse
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/render"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+	"github.com/BlackOrder/complete-command/internal/tmpl"
+)
+
+// maxSkipped is the number of line words a match may leave unexplained,
+// together with the literal template words it may leave out.
+const maxSkipped = 3
+
+// Match is one way of reading a command line as an action.
+type Match struct {
+	Action *registry.Action
+	Tool   string
+	// Values holds the recovered field values, converted to their field
+	// types.  Fields the line says nothing about are absent.
+	Values map[string]interface{}
+	// Skipped lists the words of the line that the template does not
+	// account for.
+	Skipped []string
+	// Exact reports whether rendering the template with Values reproduces
+	// the line word for word with every required field set.
+	Exact bool
+
+	literals int // literal template words matched
+	slots    int // field values matched
+	missing  int // required fields left empty
+	dropped  int // literal template words missing from the line
+	words    int // words in the line
+}
+
+// Confidence estimates how likely the match is the intended reading of the
+// line, between 0 and 1.  It is the share of the line's words the template
+// accounts for, reduced when rendering does not reproduce the line, by a
+// tenth for every template word missing from the line and to a quarter for
+// every required field left empty.
+func (m Match) Confidence() float64 {
+	if m.words == 0 {
+		return 0
+	}
+	c := float64(m.words-len(m.Skipped)) / float64(m.words)
+	if !m.Exact {
+		c *= 0.8
+	}
+	for i := 0; i < m.dropped; i++ {
+		c *= 0.9
+	}
+	for i := 0; i < m.missing; i++ {
+		c /= 4
+	}
+	// Round away the error the factors accumulate.
+	return math.Round(c*1e9) / 1e9
+}
+
+// Score ranks matches: matched literal words count most, recovered values
+// less, and unexplained words, template words missing from the line and
+// missing required values count against the match.
+func (m Match) Score() float64 {
+	s := 2*float64(m.literals) + float64(m.slots) - 3*float64(len(m.Skipped)) - 2*float64(m.dropped) - float64(m.missing)
+	if m.Exact {
+		s++
+	}
+	return s
+}
+
+// Parse reads line as each action of reg and returns the possible matches,
+// best first.  It returns nil when no template fits.
+func Parse(reg *registry.Registry, line string) []Match {
+	words := trimSudo(shellquote.Split(line))
+	if len(words) == 0 {
+		return nil
+	}
+	var ms []Match
+	for i := range reg.Actions {
+		ms = append(ms, parseAction(&reg.Actions[i], words)...)
+	}
+	sortMatches(ms)
+	return ms
+}
+
+// ParseAction is like Parse but only considers act.
+func ParseAction(act *registry.Action, line string) []Match {
+	words := trimSudo(shellquote.Split(line))
+	if len(words) == 0 {
+		return nil
+	}
+	ms := parseAction(act, words)
+	sortMatches(ms)
+	return ms
+}
+
+func sortMatches(ms []Match) {
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Score() > ms[j].Score() })
+}
+
+// parseAction returns the best match of words for each tool of act.
+func parseAction(act *registry.Action, words []string) []Match {
+	fields := make(map[string]registry.Field, len(act.Fields))
+	for _, f := range act.Fields {
+		fields[f.Key] = f
+	}
+	var ms []Match
+	for _, tool := range act.Candidates {
+		src, ok := act.Template[tool]
+		if !ok {
+			continue
+		}
+		t, err := tmpl.Parse(src)
+		if err != nil {
+			continue
+		}
+		var best *Match
+		for _, v := range variants(t.Root, fields) {
+			for _, m := range v.match(words, fields) {
+				m.Action, m.Tool, m.words = act, tool, len(words)
+				for _, f := range act.Fields {
+					if f.Required && blank(m.Values[f.Key]) {
+						m.missing++
+					}
+				}
+				m.Exact = m.missing == 0 && exact(act, tool, m.Values, words)
+				if best == nil || m.Score() > best.Score() {
+					m := m
+					best = &m
+				}
+			}
+		}
+		if best != nil {
+			ms = append(ms, *best)
+		}
+	}
+	return ms
+}
+
+// exact reports whether act renders to words for tool with values, fields
+// not mentioned being empty.
+func exact(act *registry.Action, tool string, values map[string]interface{}, words []string) bool {
+	full := make(map[string]interface{}, len(act.Fields))
+	for _, f := range act.Fields {
+		if v, ok := values[f.Key]; ok {
+			full[f.Key] = v
+		} else {
+			full[f.Key] = zero(f)
+		}
+	}
+	cmd, err := render.Command(*act, tool, full, shellquote.Sh)
+	if err != nil {
+		return false
+	}
+	got := trimSudo(shellquote.Split(cmd))
+	if len(got) != len(words) {
+		return false
+	}
+	for i := range got {
+		if got[i] != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// zero returns the value a field has when a command leaves it out.
+func zero(f registry.Field) interface{} {
+	switch f.Type {
+	case "bool":
+		return false
+	case "int":
+		return 0
+	case "float":
+		return 0.0
+	case "multi":
+		return []string(nil)
+	}
+	return ""
+}
+
+// blank reports whether a recovered value leaves its field empty.
+func blank(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(vv) == ""
+	case []string:
+		return len(vv) == 0
+	}
+	return false
+}
+
+// trimSudo drops a leading sudo, which users add or leave out freely.
+func trimSudo(words []string) []string {
+	if len(words) > 0 && words[0] == "sudo" {
+		return words[1:]
+	}
+	return words
+}
@@ -0,0 +1,181 @@
+package reverse
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/render"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+func load(t *testing.T) *registry.Registry {
+	t.Helper()
+	reg, err := registry.Load("../../registry.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestParse(t *testing.T) {
+	reg := load(t)
+	tests := []struct {
+		line   string
+		action string
+		tool   string
+		values map[string]interface{}
+	}{
+		{"rg -i foo src", "search/files", "rg", map[string]interface{}{
+			"literal": false, "ignore": true, "word": false, "hidden": false, "filesWith": false,
+			"ctx": 0, "query": "foo", "dir": "src",
+		}},
+		// Options in another order than the template's.
+		{"rg foo -C 3 -F src -g '*.go'", "search/files", "rg", map[string]interface{}{
+			"literal": true, "ignore": false, "word": false, "hidden": false, "filesWith": false,
+			"ctx": 3, "glob": "*.go", "query": "foo", "dir": "src",
+		}},
+		{"ssh -p 2222 bob@example.com", "ssh/login", "ssh", map[string]interface{}{
+			"port": 2222, "user": "bob", "host": "example.com",
+		}},
+		{"ssh example.com -p 2222", "ssh/login", "ssh", map[string]interface{}{
+			"port": 2222, "user": "", "host": "example.com",
+		}},
+		{"ssh example.com", "ssh/login", "ssh", map[string]interface{}{
+			"port": 22, "user": "", "host": "example.com",
+		}},
+		{`curl -sS -X POST -H 'Accept: text/html' -H "X-A: 1" --data 'a b' https://example.com`, "net/http", "curl", map[string]interface{}{
+			"method": "POST", "header": []string{"Accept: text/html", "X-A: 1"}, "data": "a b", "url": "https://example.com",
+		}},
+		{"sudo useradd -m bob", "user/add", "useradd", map[string]interface{}{"name": "bob"}},
+		{"useradd -m bob", "user/add", "useradd", map[string]interface{}{"name": "bob"}},
+		{"tar -czf out.tgz a b c", "compress/tar-gz", "tar", map[string]interface{}{
+			"archive": "out.tgz", "paths": []string{"a", "b", "c"},
+		}},
+		{"7z x a.zip -oout", "decompress/zip", "7z", map[string]interface{}{"file": "a.zip", "dir": "out"}},
+		{"host -t PTR 1.1.1.1", "net/dns-lookup", "host", map[string]interface{}{"reverse": true, "name": "1.1.1.1"}},
+		// Half-typed lines leaving out the template's "-lah".
+		{"ls", "file/ls", "ls", map[string]interface{}{"all": false}},
+		{"ls -la", "file/ls", "ls", map[string]interface{}{"all": false}},
+		{"ls -l src", "file/ls", "ls", map[string]interface{}{"all": false, "dir": "src"}},
+	}
+	// Words of the lines that no template accounts for.
+	skipped := map[string][]string{"ls -la": {"-la"}, "ls -l src": {"-l"}}
+	for _, tt := range tests {
+		ms := Parse(reg, tt.line)
+		if len(ms) == 0 {
+			t.Errorf("Parse(%q): no match", tt.line)
+			continue
+		}
+		m := ms[0]
+		if m.Action.ID != tt.action || m.Tool != tt.tool {
+			t.Errorf("Parse(%q) = %s/%s, want %s/%s", tt.line, m.Action.ID, m.Tool, tt.action, tt.tool)
+			continue
+		}
+		if !reflect.DeepEqual(m.Values, tt.values) {
+			t.Errorf("Parse(%q) values = %#v, want %#v", tt.line, m.Values, tt.values)
+		}
+		if want := skipped[tt.line]; !reflect.DeepEqual(m.Skipped, want) {
+			t.Errorf("Parse(%q) skipped %q, want %q", tt.line, m.Skipped, want)
+		}
+	}
+}
+
+func TestParseSkipped(t *testing.T) {
+	ms := Parse(load(t), "rg --sort=path foo")
+	if len(ms) == 0 || ms[0].Tool != "rg" {
+		t.Fatalf("Parse: %+v", ms)
+	}
+	if got := ms[0].Values["query"]; got != "foo" {
+		t.Errorf("query = %v, want foo", got)
+	}
+	if want := []string{"--sort=path"}; !reflect.DeepEqual(ms[0].Skipped, want) {
+		t.Errorf("skipped = %q, want %q", ms[0].Skipped, want)
+	}
+	if ms[0].Exact {
+		t.Error("match with skipped words reported as exact")
+	}
+}
+
+func TestParseNoMatch(t *testing.T) {
+	reg := load(t)
+	for _, line := range []string{"", "   ", "frobnicate --all", "sudo"} {
+		if ms := Parse(reg, line); len(ms) != 0 {
+			t.Errorf("Parse(%q) = %s/%s, want no match", line, ms[0].Action.ID, ms[0].Tool)
+		}
+	}
+}
+
+// TestRoundTrip renders every action with sample values and checks that the
+// command is read back exactly.
+func TestRoundTrip(t *testing.T) {
+	reg := load(t)
+	samples := map[string]interface{}{
+		"string": "some value", "path": "/tmp/a b", "int": 7, "float": 1.5, "bool": true,
+		"multi": []string{"K:one", "K:two"},
+	}
+	for i := range reg.Actions {
+		act := &reg.Actions[i]
+		values := render.Defaults(*act)
+		for _, f := range act.Fields {
+			if v, ok := samples[f.Type]; ok {
+				values[f.Key] = v
+			}
+		}
+		for _, tool := range act.Candidates {
+			cmd, err := render.Command(*act, tool, values, shellquote.Sh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms := ParseAction(act, cmd)
+			found := false
+			for _, m := range ms {
+				if m.Tool == tool && m.Exact {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s/%s: %q not read back exactly: %+v", act.ID, tool, cmd, ms)
+			}
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	reg := load(t)
+	tests := []struct {
+		line string
+		want float64
+	}{
+		{"rg -i foo src", 1},
+		// One word of four is not accounted for and the line is not
+		// reproduced exactly.
+		{"rg --sort=path foo src", 0.75 * 0.8},
+		// The required query is missing, so the match is not exact
+		// either.
+		{"rg -i", 0.8 * 0.25},
+		// The template's "-lah" is missing from the line.
+		{"ls", 0.8 * 0.9},
+	}
+	for _, tt := range tests {
+		ms := Parse(reg, tt.line)
+		if len(ms) == 0 {
+			t.Errorf("Parse(%q): no match", tt.line)
+			continue
+		}
+		if got := ms[0].Confidence(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Parse(%q) confidence = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestExactRequiresFields(t *testing.T) {
+	ms := Parse(load(t), "rg -i")
+	if len(ms) == 0 || ms[0].Tool != "rg" {
+		t.Fatalf("Parse: %+v", ms)
+	}
+	if ms[0].Exact {
+		t.Error("match missing the required query reported as exact")
+	}
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLines is the number of output lines kept; older lines are dropped.
+const MaxLines = 10000
+
+// Output turns the raw output of a terminal program into lines of text.  It
+// keeps colour (SGR) escape sequences and drops all other control sequences.
+// A carriage return starts the line over, so progress bars show their last
+// state, and a backspace erases the previous character.
+type Output struct {
+	lines []string
+	cur   []byte
+	// cr is set after a carriage return: the next text replaces the line.
+	cr bool
+	// seq holds an escape sequence split across writes.
+	seq []byte
+}
+
+// Write adds output.  It never fails.
+func (o *Output) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if o.seq != nil {
+			o.escape(c)
+			continue
+		}
+		switch {
+		case c == 0x1b:
+			o.seq = []byte{c}
+		case c == '\n':
+			o.lines = append(o.lines, string(o.cur))
+			if len(o.lines) > MaxLines {
+				o.lines = o.lines[len(o.lines)-MaxLines:]
+			}
+			o.cur, o.cr = nil, false
+		case c == '\r':
+			o.cr = true
+		case c == '\b':
+			if _, size := utf8.DecodeLastRune(o.cur); size > 0 {
+				o.cur = o.cur[:len(o.cur)-size]
+			}
+		case c < 0x20 && c != '\t', c == 0x7f:
+			// Other control characters, such as the bell, are dropped.
+		default:
+			if o.cr {
+				o.cur, o.cr = o.cur[:0], false
+			}
+			o.cur = append(o.cur, c)
+		}
+	}
+	return len(p), nil
+}
+
+// escape continues the escape sequence in o.seq with c.
+func (o *Output) escape(c byte) {
+	o.seq = append(o.seq, c)
+	if len(o.seq) == 2 {
+		if c != '[' && c != ']' {
+			// A two-byte sequence such as ESC =.
+			o.seq = nil
+		}
+		return
+	}
+	switch o.seq[1] {
+	case '[':
+		// CSI: parameters up to a final byte in 0x40–0x7e.
+		if c >= 0x40 && c <= 0x7e {
+			if c == 'm' {
+				o.cur = append(o.cur, o.seq...)
+			}
+			o.seq = nil
+		}
+	case ']':
+		// OSC: up to BEL or ESC \.
+		if c == 0x07 || (c == '\\' && o.seq[len(o.seq)-2] == 0x1b) {
+			o.seq = nil
+		}
+	}
+}
+
+// String returns the output so far.  A colour left on at the end of a line
+// is reset so it does not leak into the next one.
+func (o *Output) String() string {
+	lines := o.lines
+	if len(o.cur) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(o.cur))
+	}
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(l)
+		if strings.Contains(l, "\x1b[") {
+			b.WriteString("\x1b[0m")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+// Package runner executes built commands in a pseudo-terminal, so tools that
+// colour their output or check for a terminal behave as they would at the
+// prompt, and streams the output back for display.
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// Event is a piece of output from a running command or, once Done is set,
+// its outcome.
+type Event struct {
+	Output []byte
+
+	Done bool
+	// Exit is the exit status; -1 when the command could not be waited for
+	// or was killed by a signal.
+	Exit     int
+	Err      error
+	Duration time.Duration
+}
+
+// Run is a command started by Start.
+type Run struct {
+	Command string
+	Started time.Time
+
+	cmd    *exec.Cmd
+	pty    *os.File
+	events chan Event
+}
+
+// Shell returns the shell commands are run with: $SHELL, or /bin/sh when it
+// is not set.
+func Shell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	return "/bin/sh"
+}
+
+// Start runs command with shell -c in a new pseudo-terminal of the given
+// size.  Output and the final outcome are delivered on Events.
+func Start(shell, command string, cols, rows int) (*Run, error) {
+	c := exec.Command(shell, "-c", command)
+	f, err := pty.StartWithSize(c, winsize(cols, rows))
+	if err != nil {
+		return nil, err
+	}
+	r := &Run{Command: command, Started: time.Now(), cmd: c, pty: f, events: make(chan Event, 64)}
+	go r.read()
+	return r, nil
+}
+
+// Events returns the channel the run's events are sent on.  It is closed
+// after the Done event.
+func (r *Run) Events() <-chan Event { return r.events }
+
+// Interrupt types Ctrl+C into the terminal, which interrupts the command
+// like it would at the prompt.
+func (r *Run) Interrupt() error {
+	_, err := r.pty.Write([]byte{0x03})
+	return err
+}
+
+// Kill stops the command immediately, together with every process it
+// started.  The command leads its own session, so its process group holds
+// the stages of a pipeline and other children that keep the terminal open.
+func (r *Run) Kill() error {
+	return syscall.Kill(-r.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// Resize changes the size of the command's terminal.
+func (r *Run) Resize(cols, rows int) error {
+	return pty.Setsize(r.pty, winsize(cols, rows))
+}
+
+func (r *Run) read() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.pty.Read(buf)
+		if n > 0 {
+			r.events <- Event{Output: append([]byte(nil), buf[:n]...)}
+		}
+		// Reading fails once the command and its children have exited.
+		if err != nil {
+			break
+		}
+	}
+	err := r.cmd.Wait()
+	r.pty.Close()
+	ev := Event{Done: true, Duration: time.Since(r.Started), Exit: r.cmd.ProcessState.ExitCode()}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		ev.Err = err
+	}
+	r.events <- ev
+	close(r.events)
+}
+
+func winsize(cols, rows int) *pty.Winsize {
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+	return &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}
+}
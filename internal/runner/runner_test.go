@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"a\r\nb\n"}, "a\nb"},
+		// Progress output shows its last state.
+		{[]string{"10%\r50%\r100%\ndone"}, "100%\ndone"},
+		{[]string{"abc\b\bX"}, "aX"},
+		// Colours are kept and reset at the end of the line; other
+		// sequences, even split across writes, are dropped.
+		{[]string{"\x1b[31mred\x1b", "[K\x1b]0;title\x07!\n"}, "\x1b[31mred!\x1b[0m"},
+		{[]string{"a\x07\tb"}, "a\tb"},
+	}
+	for _, tt := range tests {
+		var o Output
+		for _, s := range tt.in {
+			o.Write([]byte(s))
+		}
+		if got := o.String(); got != tt.want {
+			t.Errorf("Output(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	r, err := Start("/bin/sh", "printf 'out\\n'; test -t 1 && echo tty; exit 3", 80, 24)
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	var o Output
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, ok := <-r.Events():
+			if !ok {
+				t.Fatal("events closed before the done event")
+			}
+			o.Write(ev.Output)
+			if !ev.Done {
+				continue
+			}
+			if ev.Exit != 3 || ev.Err != nil {
+				t.Errorf("exit = %d, %v, want 3", ev.Exit, ev.Err)
+			}
+			if got := strings.TrimSpace(o.String()); got != "out\ntty" {
+				t.Errorf("output = %q, want %q", got, "out\ntty")
+			}
+			return
+		case <-timeout:
+			r.Kill()
+			t.Fatal("command did not finish")
+		}
+	}
+}
+
+// TestKill checks that killing a pipeline stops all of its stages, so the
+// run finishes.  The stages ignore the hangup sent when the shell dies, as
+// nohup'd commands do, and would otherwise keep the terminal open.
+func TestKill(t *testing.T) {
+	r, err := Start("/bin/sh", "trap '' HUP; sleep 100 | (echo started; cat)", 80, 24)
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	var o Output
+	killed := false
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, ok := <-r.Events():
+			if !ok {
+				t.Fatal("events closed before the done event")
+			}
+			o.Write(ev.Output)
+			// Kill once the pipeline is running.
+			if !killed && strings.Contains(o.String(), "started") {
+				if err := r.Kill(); err != nil {
+					t.Fatal(err)
+				}
+				killed = true
+			}
+			if ev.Done {
+				if !killed || ev.Exit != -1 {
+					t.Errorf("exit = %d, want -1 for a killed command", ev.Exit)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("killed command did not finish")
+		}
+	}
+}
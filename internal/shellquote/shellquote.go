@@ -0,0 +1,335 @@
+// Package shellquote escapes arbitrary strings so they can be pasted into a
+// command line as a single word for a particular shell.  Values that consist
+// only of characters no shell treats specially are returned unchanged.
+package shellquote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Shell identifies the quoting dialect to produce.
+type Shell int
+
+// Supported shells.  The zero value is POSIX sh, which is also a safe choice
+// for any shell not listed here.
+const (
+	Sh Shell = iota
+	Bash
+	Zsh
+	Fish
+)
+
+// String returns the shell's conventional name.
+func (s Shell) String() string {
+	switch s {
+	case Bash:
+		return "bash"
+	case Zsh:
+		return "zsh"
+	case Fish:
+		return "fish"
+	default:
+		return "sh"
+	}
+}
+
+// Detect maps a shell name or path (such as the value of $SHELL) to a Shell.
+// Unknown shells fall back to POSIX sh.
+func Detect(shell string) Shell {
+	switch filepath.Base(shell) {
+	case "bash":
+		return Bash
+	case "zsh":
+		return Zsh
+	case "fish":
+		return Fish
+	default:
+		return Sh
+	}
+}
+
+// FromEnv returns the Shell named by the SHELL environment variable.
+func FromEnv() Shell {
+	return Detect(os.Getenv("SHELL"))
+}
+
+// Quote returns s escaped as a single word for the given shell.
+func Quote(sh Shell, s string) string {
+	if s == "" {
+		return "''"
+	}
+	if isSafe(s) {
+		return s
+	}
+	switch sh {
+	case Bash, Zsh:
+		if hasControl(s) {
+			return ansiC(s)
+		}
+		return posix(s)
+	case Fish:
+		return fish(s)
+	default:
+		return posix(s)
+	}
+}
+
+// Join quotes every argument and joins them with spaces.
+func Join(sh Shell, args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = Quote(sh, a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// QuoteGlob is like Quote but leaves the glob metacharacters of s for the
+// shell to expand: "*", "?" and bracket expressions such as "[a-z]", of
+// which fish only knows "*".  The text between them is quoted, so a pattern
+// such as "my dir/*.log" matches the files in "my dir".
+func QuoteGlob(sh Shell, s string) string {
+	var b, lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			b.WriteString(Quote(sh, lit.String()))
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		n := 0
+		switch {
+		case c == '*':
+			n = 1
+		case c == '?' && sh != Fish:
+			n = 1
+		case c == '[' && sh != Fish:
+			n = bracket(s[i:])
+		}
+		if n == 0 {
+			lit.WriteByte(c)
+			continue
+		}
+		flush()
+		b.WriteString(s[i : i+n])
+		i += n - 1
+	}
+	flush()
+	return b.String()
+}
+
+// bracket returns the length of the bracket expression s starts with, or 0
+// when there is none or it holds characters that are only safe quoted.
+func bracket(s string) int {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ']' && i > 1:
+			return i + 1
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!^-_.", c) >= 0:
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// isSafe reports whether s can be used as a word without quoting in every
+// supported shell.  A leading "=" is not safe: zsh expands "=cmd" to the
+// path of cmd.
+func isSafe(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '=' && i > 0:
+		case strings.IndexByte("_@+:,./-", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// posix wraps s in single quotes, closing and reopening the quotes around
+// each embedded single quote.  Everything else is literal inside '…'.
+func posix(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ansiC produces a bash/zsh $'…' string so control characters such as
+// newlines survive as escapes rather than raw bytes.
+func ansiC(s string) string {
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// fish single quotes only recognise \\ and \' as escapes.
+func fish(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// Split breaks a command line into words the way a POSIX shell would, with
+// quotes and escapes removed: '…' is literal, "…" honours backslash escapes
+// of $ ` " and \, a backslash outside quotes escapes the next character and
+// bash/zsh $'…' strings decode their C-style escapes.  It is lenient so that
+// half-typed lines can be split: an unterminated quote runs to the end of the
+// input and a trailing backslash is kept.  Shell operators such as | are not
+// treated specially and become words of their own when space-separated.
+func Split(line string) []string {
+	var words []string
+	for _, w := range Words(line) {
+		words = append(words, w.Text)
+	}
+	return words
+}
+
+// Word is a word of a command line.
+type Word struct {
+	// Text is the word with quotes and escapes removed.
+	Text string
+	// Start and End are the byte offsets of the word as written in the line.
+	Start, End int
+}
+
+// Words splits line like Split and also reports where each word is written.
+func Words(line string) []Word {
+	var words []Word
+	var cur strings.Builder
+	start := -1
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if start < 0 && c != ' ' && c != '\t' && c != '\n' {
+			start = i
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if start >= 0 {
+				words = append(words, Word{cur.String(), start, i})
+				cur.Reset()
+				start = -1
+			}
+		case c == '\'':
+			j := strings.IndexByte(line[i+1:], '\'')
+			if j < 0 {
+				j = len(line) - i - 1
+			}
+			cur.WriteString(line[i+1 : i+1+j])
+			i += j + 1
+		case c == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("$`\"\\\n", line[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(line[i])
+			}
+		case c == '$' && i+1 < len(line) && line[i+1] == '\'':
+			i = unescapeANSIC(line, i+2, &cur)
+		case c == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if start >= 0 {
+		words = append(words, Word{cur.String(), start, len(line)})
+	}
+	return words
+}
+
+// unescapeANSIC decodes the body of a $'…' string starting at line[i] into
+// cur and returns the index of the closing quote.
+func unescapeANSIC(line string, i int, cur *strings.Builder) int {
+	for ; i < len(line) && line[i] != '\''; i++ {
+		if line[i] != '\\' || i+1 >= len(line) {
+			cur.WriteByte(line[i])
+			continue
+		}
+		i++
+		switch e := line[i]; e {
+		case 'n':
+			cur.WriteByte('\n')
+		case 't':
+			cur.WriteByte('\t')
+		case 'r':
+			cur.WriteByte('\r')
+		case 'a':
+			cur.WriteByte('\a')
+		case 'b':
+			cur.WriteByte('\b')
+		case 'e', 'E':
+			cur.WriteByte(0x1b)
+		case 'f':
+			cur.WriteByte('\f')
+		case 'v':
+			cur.WriteByte('\v')
+		case 'x':
+			n, j := 0, i+1
+			for ; j < len(line) && j < i+3 && isHex(line[j]); j++ {
+				n = n*16 + hexVal(line[j])
+			}
+			if j == i+1 {
+				cur.WriteString(`\x`)
+				continue
+			}
+			cur.WriteByte(byte(n))
+			i = j - 1
+		default:
+			// \\, \', \" and unknown escapes yield the character itself.
+			cur.WriteByte(e)
+		}
+	}
+	return i
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexVal(c byte) int {
+	switch {
+	case c <= '9':
+		return int(c - '0')
+	case c >= 'a':
+		return int(c-'a') + 10
+	}
+	return int(c-'A') + 10
+}
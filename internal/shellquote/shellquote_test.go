@@ -0,0 +1,190 @@
+package shellquote
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var samples = []string{
+	"plain",
+	"",
+	"two words",
+	"it's",
+	`say "hi"`,
+	"line1\nline2",
+	"tab\there",
+	"*.go",
+	"src/**/[a-z]?.log",
+	"$(rm -rf ~)",
+	"`id`",
+	"$HOME",
+	`back\slash`,
+	"~user",
+	"a;b|c&d",
+	"'",
+	"''",
+	"{a,b}",
+	"=ls",
+	"#comment",
+	"ünïcödé",
+}
+
+func TestQuoteExpected(t *testing.T) {
+	cases := []struct {
+		sh   Shell
+		in   string
+		want string
+	}{
+		{Sh, "plain", "plain"},
+		{Sh, "", "''"},
+		{Sh, "it's", `'it'\''s'`},
+		{Sh, "*.go", `'*.go'`},
+		{Sh, "$(id)", `'$(id)'`},
+		{Sh, "a\nb", "'a\nb'"},
+		{Bash, "a\nb", `$'a\nb'`},
+		{Zsh, "it's\n", `$'it\'s\n'`},
+		{Zsh, "=ls", "'=ls'"},
+		{Zsh, "a=b", "a=b"},
+		{Bash, "it's", `'it'\''s'`},
+		{Fish, "it's", `'it\'s'`},
+		{Fish, `a\b`, `'a\\b'`},
+		{Fish, "$(id)", `'$(id)'`},
+	}
+	for _, c := range cases {
+		if got := Quote(c.sh, c.in); got != c.want {
+			t.Errorf("Quote(%v, %q) = %s, want %s", c.sh, c.in, got, c.want)
+		}
+	}
+}
+
+// TestQuoteRoundTrip feeds quoted samples to real shells and checks that
+// each comes back as exactly one unchanged argument.
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, sh := range []Shell{Sh, Bash, Zsh, Fish} {
+		bin, err := exec.LookPath(sh.String())
+		if err != nil {
+			t.Logf("%s not installed; skipping round trip", sh)
+			continue
+		}
+		for _, s := range samples {
+			script := "printf '%s' " + Quote(sh, s)
+			out, err := exec.Command(bin, "-c", script).Output()
+			if err != nil {
+				t.Errorf("%s: running %q: %v", sh, script, err)
+				continue
+			}
+			if string(out) != s {
+				t.Errorf("%s: Quote(%q) round-tripped to %q", sh, s, out)
+			}
+		}
+	}
+}
+
+func TestQuoteGlob(t *testing.T) {
+	cases := []struct {
+		sh   Shell
+		in   string
+		want string
+	}{
+		{Sh, "*.go", "*.go"},
+		{Sh, "my dir/*.log", `'my dir/'*.log`},
+		{Sh, "src/[a-z]?.go", "src/[a-z]?.go"},
+		{Sh, "it's*", `'it'\''s'*`},
+		{Sh, "a[;]b", `'a[;]b'`},
+		{Fish, "my dir/?.log", `'my dir/?.log'`},
+		{Fish, "a b*", `'a b'*`},
+	}
+	for _, c := range cases {
+		if got := QuoteGlob(c.sh, c.in); got != c.want {
+			t.Errorf("QuoteGlob(%v, %q) = %s, want %s", c.sh, c.in, got, c.want)
+		}
+	}
+}
+
+// TestQuoteGlobExpands checks with real shells that a quoted pattern
+// expands to the files it names, and only those.
+func TestQuoteGlobExpands(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "c.txt", "$x.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sh := range []Shell{Sh, Bash, Zsh, Fish} {
+		bin, err := exec.LookPath(sh.String())
+		if err != nil {
+			continue
+		}
+		script := "printf '%s\\n' " + QuoteGlob(sh, "$x*.log")
+		cmd := exec.Command(bin, "-c", script)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%s: running %q: %v", sh, script, err)
+			continue
+		}
+		if string(out) != "$x.log\n" {
+			t.Errorf("%s: %q printed %q, want $x.log", sh, script, out)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]Shell{
+		"/bin/bash":          Bash,
+		"/usr/local/bin/zsh": Zsh,
+		"fish":               Fish,
+		"/bin/dash":          Sh,
+		"":                   Sh,
+	}
+	for in, want := range cases {
+		if got := Detect(in); got != want {
+			t.Errorf("Detect(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"rg -i foo  src", []string{"rg", "-i", "foo", "src"}},
+		{`echo 'a b' "c \"d\" \x" e\ f`, []string{"echo", "a b", `c "d" \x`, "e f"}},
+		{`x 'it'\''s' ""`, []string{"x", "it's", ""}},
+		{`x $'a\nb\x41\'' y`, []string{"x", "a\nbA'", "y"}},
+		{`grep 'half typed`, []string{"grep", "half typed"}},
+		{`a"b"'c'd`, []string{"abcd"}},
+		{"ps aux | head", []string{"ps", "aux", "|", "head"}},
+		{"   ", nil},
+	}
+	for _, c := range cases {
+		if got := Split(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Split(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	// Anything quoted for sh or bash splits back into the original words.
+	for _, sh := range []Shell{Sh, Bash} {
+		if got := Split(Join(sh, samples...)); !reflect.DeepEqual(got, samples) {
+			t.Errorf("Split(Join(%v, samples)) = %q", sh, got)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	line := ` rg -i 'a b' "c\"d"e $'x\ty'  `
+	var raw, text []string
+	for _, w := range Words(line) {
+		raw = append(raw, line[w.Start:w.End])
+		text = append(text, w.Text)
+	}
+	if want := []string{"rg", "-i", "'a b'", `"c\"d"e`, `$'x\ty'`}; !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw words = %q, want %q", raw, want)
+	}
+	if want := []string{"rg", "-i", "a b", `c"de`, "x\ty"}; !reflect.DeepEqual(text, want) {
+		t.Errorf("words = %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,56 @@
+// Package statefile guards the files that concurrent sessions share, such
+// as the configuration and the frecency state: an exclusive lock serialises
+// their read-modify-write cycles and writes replace a file atomically, so a
+// reader never sees it half written.
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Lock takes an exclusive lock guarding the file at path against other
+// sessions and returns the function releasing it.  The lock is held on a
+// separate path + ".lock" file, since the file itself is replaced on
+// writes.
+func Lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// Write replaces the file at path with data atomically: data is written to
+// a temporary file in the same directory that is then renamed over it.
+func Write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
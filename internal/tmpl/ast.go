@@ -0,0 +1,89 @@
+package tmpl
+
+import "github.com/BlackOrder/complete-command/internal/cond"
+
+// Template is a parsed template.
+type Template struct {
+	Src  string
+	Root []Node
+}
+
+// Node is an element of a parsed template.
+type Node interface {
+	// Position returns the byte offset of the node in the source.
+	Position() int
+}
+
+// TextNode is literal command text.
+type TextNode struct {
+	Pos  int
+	Text string
+}
+
+// ActionNode substitutes a value: {{operand | filter arg ...}}.
+type ActionNode struct {
+	Pos int
+	// Field is the key of the substituted field, empty for a literal.
+	Field string
+	// Literal is the value of a string or number operand.
+	Literal string
+	Filters []Filter
+}
+
+// Filter is a single filter of an action pipeline.
+type Filter struct {
+	Pos  int
+	Name string
+	Args []string
+}
+
+// IfNode is a conditional with optional else-if branches and else body.
+type IfNode struct {
+	Pos      int
+	Branches []Branch
+	Else     []Node
+}
+
+// Branch is one condition of an IfNode with the nodes it guards.
+type Branch struct {
+	Pos  int
+	Src  string
+	Cond cond.Expr
+	Body []Node
+}
+
+func (n *TextNode) Position() int   { return n.Pos }
+func (n *ActionNode) Position() int { return n.Pos }
+func (n *IfNode) Position() int     { return n.Pos }
+
+// Keys returns the field keys referenced by the template, in order of first
+// appearance, including those used in conditions.
+func (t *Template) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	var walk func([]Node)
+	walk = func(nodes []Node) {
+		for _, n := range nodes {
+			switch n := n.(type) {
+			case *ActionNode:
+				add(n.Field)
+			case *IfNode:
+				for _, b := range n.Branches {
+					for _, id := range b.Cond.Idents() {
+						add(id)
+					}
+					walk(b.Body)
+				}
+				walk(n.Else)
+			}
+		}
+	}
+	walk(t.Root)
+	return keys
+}
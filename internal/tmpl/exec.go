@@ -0,0 +1,255 @@
+package tmpl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BlackOrder/complete-command/internal/cond"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// Execute renders the template with values, quoting string values for the
+// given shell.  Conditions see values as field values; a missing key is
+// treated as empty.
+func (t *Template) Execute(values map[string]interface{}, sh shellquote.Shell) string {
+	out, _ := t.ExecuteSpans(values, sh)
+	return out
+}
+
+// Span is the part of a rendered command produced by one template node,
+// as byte offsets into the command.  Field is the field it comes from: the
+// field substituted by an action or, for text inside a conditional, the
+// first field the condition tests.  It is empty for unconditional text.
+type Span struct {
+	Start, End int
+	Field      string
+}
+
+// ExecuteSpans is like Execute but also returns the spans of the command,
+// in order, so each part can be traced back to a field.
+func (t *Template) ExecuteSpans(values map[string]interface{}, sh shellquote.Shell) (string, []Span) {
+	var out strings.Builder
+	var spans []Span
+	execList(&out, t.Root, cond.Env{Values: values}, sh, "", &spans)
+	// Output never starts with whitespace, so trimming the end keeps the
+	// offsets valid.
+	cmd := strings.TrimRightFunc(out.String(), unicode.IsSpace)
+	kept := spans[:0]
+	for _, sp := range spans {
+		if sp.End > len(cmd) {
+			sp.End = len(cmd)
+		}
+		if sp.Start < sp.End {
+			kept = append(kept, sp)
+		}
+	}
+	return cmd, kept
+}
+
+func execList(out *strings.Builder, nodes []Node, env cond.Env, sh shellquote.Shell, field string, spans *[]Span) {
+	for _, n := range nodes {
+		start := out.Len()
+		switch n := n.(type) {
+		case *TextNode:
+			writeText(out, n.Text)
+			*spans = append(*spans, Span{start, out.Len(), field})
+		case *ActionNode:
+			writeExpansion(out, n.eval(env.Values, sh))
+			f := n.Field
+			if f == "" {
+				f = field
+			}
+			*spans = append(*spans, Span{start, out.Len(), f})
+		case *IfNode:
+			execList(out, n.body(env), env, sh, n.field(field), spans)
+		}
+	}
+}
+
+// field returns the first field tested by the conditional, other than the
+// tool, or outer when there is none.
+func (n *IfNode) field(outer string) string {
+	for _, id := range n.Branches[0].Cond.Idents() {
+		if id != "tool" {
+			return id
+		}
+	}
+	return outer
+}
+
+// body returns the nodes of the first branch whose condition holds, or the
+// else body.
+func (n *IfNode) body(env cond.Env) []Node {
+	for _, b := range n.Branches {
+		if b.Cond.Eval(env) {
+			return b.Body
+		}
+	}
+	return n.Else
+}
+
+// value is an action value flowing through its filters.  Once fmt has been
+// applied the value becomes a list of rendered fragments.
+type value struct {
+	v         interface{}
+	raw       bool
+	formatted bool
+	frags     []string
+}
+
+// eval renders a single action.
+func (n *ActionNode) eval(values map[string]interface{}, sh shellquote.Shell) string {
+	val := value{v: values[n.Field]}
+	if n.Field == "" {
+		val = value{v: n.Literal, raw: true}
+	}
+	for _, f := range n.Filters {
+		val.apply(f, sh)
+	}
+	if val.formatted {
+		return strings.Join(val.frags, " ")
+	}
+	if empty(val.v) {
+		return ""
+	}
+	if list, ok := val.v.([]string); ok {
+		parts := make([]string, len(list))
+		for i, e := range list {
+			parts[i] = format(quote(e, val.raw, sh))
+		}
+		return strings.Join(parts, " ")
+	}
+	return format(quote(val.v, val.raw, sh))
+}
+
+func (val *value) apply(f Filter, sh shellquote.Shell) {
+	switch f.Name {
+	case "default":
+		if val.formatted && len(val.frags) == 0 {
+			val.frags = []string{f.Args[0]}
+		} else if !val.formatted && empty(val.v) {
+			val.v = f.Args[0]
+		}
+	case "join":
+		if val.formatted {
+			val.frags = []string{strings.Join(val.frags, f.Args[0])}
+		} else if list, ok := val.v.([]string); ok {
+			val.v = strings.Join(list, f.Args[0])
+		}
+	case "raw":
+		val.raw = true
+	case "fmt":
+		var frags []string
+		switch {
+		case val.formatted:
+			for _, s := range val.frags {
+				frags = append(frags, fmt.Sprintf(f.Args[0], s))
+			}
+		case empty(val.v):
+		default:
+			if list, ok := val.v.([]string); ok {
+				for _, e := range list {
+					frags = append(frags, fmt.Sprintf(f.Args[0], quote(e, val.raw, sh)))
+				}
+			} else {
+				frags = []string{fmt.Sprintf(f.Args[0], quote(val.v, val.raw, sh))}
+			}
+		}
+		val.frags, val.formatted = frags, true
+	}
+}
+
+// empty reports whether a value renders nothing: missing, an empty string or
+// an empty list.
+func empty(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return vv == ""
+	case Glob:
+		return vv == ""
+	case []string:
+		return len(vv) == 0
+	}
+	return false
+}
+
+// Glob is a string value whose glob metacharacters are left for the shell
+// to expand.  The rest of it is quoted like any string.
+type Glob string
+
+// quote shell-quotes string values unless raw is set.  Other types are
+// returned unchanged; their formatted forms never need quoting.
+func quote(v interface{}, raw bool, sh shellquote.Shell) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if !raw {
+			return shellquote.Quote(sh, vv)
+		}
+	case Glob:
+		if !raw {
+			return shellquote.QuoteGlob(sh, string(vv))
+		}
+		return string(vv)
+	}
+	return v
+}
+
+// format renders a value for a plain action.
+func format(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case bool:
+		return strconv.FormatBool(vv)
+	case int:
+		return strconv.Itoa(vv)
+	case float64:
+		return strconv.FormatFloat(vv, 'g', -1, 64)
+	default:
+		return fmt.Sprint(vv)
+	}
+}
+
+// writeText appends template text, collapsing whitespace runs into a single
+// space and avoiding a space directly after one already written.
+func writeText(out *strings.Builder, text string) {
+	space := false
+	for i := 0; i < len(text); i++ {
+		if isSpace(rune(text[i])) {
+			space = true
+			continue
+		}
+		if space {
+			writeSpace(out)
+			space = false
+		}
+		out.WriteByte(text[i])
+	}
+	if space {
+		writeSpace(out)
+	}
+}
+
+func writeSpace(out *strings.Builder) {
+	if s := out.String(); s != "" && !strings.HasSuffix(s, " ") {
+		out.WriteByte(' ')
+	}
+}
+
+// writeExpansion appends an expanded action.  Leading and trailing
+// whitespace comes from a format and is collapsed like template text; the
+// rest is written verbatim.
+func writeExpansion(out *strings.Builder, s string) {
+	core := strings.TrimFunc(s, isSpace)
+	if core == "" {
+		return
+	}
+	start := strings.Index(s, core)
+	writeText(out, s[:start])
+	out.WriteString(core)
+	writeText(out, s[start+len(core):])
+}
@@ -0,0 +1,189 @@
+package tmpl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type itemType int
+
+const (
+	itemEOF    itemType = iota
+	itemText            // command text outside actions
+	itemLeft            // {{
+	itemRight           // }}
+	itemIdent           // field key or keyword
+	itemString          // quoted string, unquoted in val
+	itemNumber          // numeric literal
+	itemPipe            // |
+	itemCond            // condition source following if
+)
+
+func (t itemType) String() string {
+	switch t {
+	case itemEOF:
+		return "end of template"
+	case itemText:
+		return "text"
+	case itemLeft:
+		return "{{"
+	case itemRight:
+		return "}}"
+	case itemIdent:
+		return "identifier"
+	case itemString:
+		return "string"
+	case itemNumber:
+		return "number"
+	case itemPipe:
+		return "|"
+	case itemCond:
+		return "condition"
+	}
+	return "item"
+}
+
+type item struct {
+	typ itemType
+	pos int
+	val string
+}
+
+// lexer splits a template into items.  Comments are dropped entirely.
+type lexer struct {
+	src   string
+	pos   int
+	items []item
+}
+
+// lex tokenizes src.  The returned slice always ends with an itemEOF.
+func lex(src string) ([]item, error) {
+	l := &lexer{src: src}
+	for l.pos < len(src) {
+		i := strings.Index(src[l.pos:], "{{")
+		if i < 0 {
+			l.emit(itemText, l.pos, src[l.pos:])
+			l.pos = len(src)
+			break
+		}
+		if i > 0 {
+			l.emit(itemText, l.pos, src[l.pos:l.pos+i])
+		}
+		l.pos += i
+		if strings.HasPrefix(src[l.pos+2:], "/*") {
+			end := strings.Index(src[l.pos+4:], "*/}}")
+			if end < 0 {
+				return nil, &Error{Pos: l.pos, Msg: "unclosed comment"}
+			}
+			l.pos += 4 + end + 4
+			continue
+		}
+		if err := l.action(); err != nil {
+			return nil, err
+		}
+	}
+	l.emit(itemEOF, len(src), "")
+	return l.items, nil
+}
+
+func (l *lexer) emit(t itemType, pos int, val string) {
+	l.items = append(l.items, item{typ: t, pos: pos, val: val})
+}
+
+// action lexes one {{…}} action starting at l.pos.
+func (l *lexer) action() error {
+	open := l.pos
+	l.emit(itemLeft, open, "{{")
+	l.pos += 2
+	first := true
+	prev := ""
+	for {
+		for l.pos < len(l.src) && isSpace(rune(l.src[l.pos])) {
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return &Error{Pos: open, Msg: "unclosed action"}
+		}
+		start := l.pos
+		rest := l.src[l.pos:]
+		switch c := rest[0]; {
+		case strings.HasPrefix(rest, "}}"):
+			l.emit(itemRight, start, "}}")
+			l.pos += 2
+			return nil
+		case c == '|':
+			l.emit(itemPipe, start, "|")
+			l.pos++
+		case c == '"' || c == '`':
+			s, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return &Error{Pos: start, Msg: "unterminated or malformed string"}
+			}
+			v, _ := strconv.Unquote(s)
+			l.emit(itemString, start, v)
+			l.pos += len(s)
+		case isDigit(c) || (c == '-' || c == '.') && len(rest) > 1 && isDigit(rest[1]):
+			l.pos++
+			for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+				l.pos++
+			}
+			l.emit(itemNumber, start, l.src[start:l.pos])
+		case isIdentStart(c):
+			for l.pos < len(l.src) && isIdentByte(l.src[l.pos]) {
+				l.pos++
+			}
+			word := l.src[start:l.pos]
+			l.emit(itemIdent, start, word)
+			if word == "if" && (first || prev == "else") {
+				l.condition()
+			}
+			prev = word
+		default:
+			return &Error{Pos: start, Msg: fmt.Sprintf("unexpected %q in action", c)}
+		}
+		first = false
+	}
+}
+
+// condition lexes the raw condition following an if keyword up to the
+// closing braces, which are left for the action loop.  Quoted strings in the
+// condition may contain braces.
+func (l *lexer) condition() {
+	for l.pos < len(l.src) && isSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	var quote byte
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				l.pos++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(l.src[l.pos:], "}}"):
+			l.emit(itemCond, start, strings.TrimRightFunc(l.src[start:l.pos], isSpace))
+			return
+		}
+		l.pos++
+	}
+	// Unclosed: the action loop reports it.
+	l.emit(itemCond, start, l.src[start:])
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentByte(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '-' || c == '.'
+}
+
+func isSpace(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' }
@@ -0,0 +1,250 @@
+package tmpl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BlackOrder/complete-command/internal/cond"
+)
+
+// filterArgs maps each filter to the number of arguments it takes.
+var filterArgs = map[string]int{
+	"default": 1,
+	"join":    1,
+	"raw":     0,
+	"fmt":     1,
+}
+
+// Parse parses a template.  Syntax errors are returned as *Error.
+func Parse(src string) (*Template, error) {
+	items, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{items: items}
+	root, stop, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if stop.typ != itemEOF {
+		return nil, &Error{Pos: stop.pos, Msg: fmt.Sprintf("unexpected {{%s}}", stop.val)}
+	}
+	return &Template{Src: src, Root: root}, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(src string) *Template {
+	t, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+type parser struct {
+	items []item
+	i     int
+}
+
+func (p *parser) peek() item { return p.items[p.i] }
+
+func (p *parser) next() item {
+	it := p.items[p.i]
+	if it.typ != itemEOF {
+		p.i++
+	}
+	return it
+}
+
+// expect consumes the next item, which must be of type t.
+func (p *parser) expect(t itemType, context string) (item, error) {
+	it := p.next()
+	if it.typ != t {
+		return it, &Error{Pos: it.pos, Msg: fmt.Sprintf("expected %s %s, found %s", t, context, describe(it))}
+	}
+	return it, nil
+}
+
+// list parses nodes up to the end of the template or an {{else}} or {{end}}
+// keyword.  It returns the item that stopped it: itemEOF, or the keyword
+// identifier with the position of its opening braces.
+func (p *parser) list() ([]Node, item, error) {
+	var nodes []Node
+	for {
+		it := p.next()
+		switch it.typ {
+		case itemEOF:
+			return nodes, it, nil
+		case itemText:
+			nodes = append(nodes, &TextNode{Pos: it.pos, Text: it.val})
+		case itemLeft:
+			kw := p.peek()
+			if kw.typ == itemIdent {
+				switch kw.val {
+				case "else", "end":
+					p.next()
+					kw.pos = it.pos
+					return nodes, kw, nil
+				case "if":
+					n, err := p.ifNode(it.pos)
+					if err != nil {
+						return nil, it, err
+					}
+					nodes = append(nodes, n)
+					continue
+				}
+			}
+			n, err := p.action(it.pos)
+			if err != nil {
+				return nil, it, err
+			}
+			nodes = append(nodes, n)
+		default:
+			return nil, it, &Error{Pos: it.pos, Msg: fmt.Sprintf("unexpected %s", describe(it))}
+		}
+	}
+}
+
+// ifNode parses {{if c}}…[{{else if c}}…]…[{{else}}…]{{end}}, starting after
+// the opening braces.
+func (p *parser) ifNode(pos int) (*IfNode, error) {
+	n := &IfNode{Pos: pos}
+	branchPos := pos
+	for {
+		p.next() // if
+		b, err := p.branch(branchPos)
+		if err != nil {
+			return nil, err
+		}
+		body, stop, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		b.Body = body
+		n.Branches = append(n.Branches, b)
+		switch {
+		case stop.typ == itemEOF:
+			return nil, &Error{Pos: pos, Msg: "unclosed {{if}}, expected {{end}}"}
+		case stop.val == "end":
+			if _, err := p.expect(itemRight, "after end"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+		// else
+		if kw := p.peek(); kw.typ == itemIdent && kw.val == "if" {
+			branchPos = stop.pos
+			continue
+		}
+		if _, err := p.expect(itemRight, "after else"); err != nil {
+			return nil, err
+		}
+		body, stop, err = p.list()
+		if err != nil {
+			return nil, err
+		}
+		n.Else = body
+		switch {
+		case stop.typ == itemEOF:
+			return nil, &Error{Pos: pos, Msg: "unclosed {{if}}, expected {{end}}"}
+		case stop.val != "end":
+			return nil, &Error{Pos: stop.pos, Msg: "unexpected {{else}} after {{else}}"}
+		}
+		if _, err := p.expect(itemRight, "after end"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+}
+
+// branch parses the condition and closing braces following an if keyword.
+func (p *parser) branch(pos int) (Branch, error) {
+	c, err := p.expect(itemCond, "after if")
+	if err != nil {
+		return Branch{}, err
+	}
+	if c.val == "" {
+		return Branch{}, &Error{Pos: c.pos, Msg: "missing condition after if"}
+	}
+	expr, err := cond.Parse(c.val)
+	if err != nil {
+		var ce *cond.Error
+		if errors.As(err, &ce) {
+			return Branch{}, &Error{Pos: c.pos + ce.Pos, Msg: "condition: " + ce.Msg}
+		}
+		return Branch{}, &Error{Pos: c.pos, Msg: err.Error()}
+	}
+	if _, err := p.expect(itemRight, "after condition"); err != nil {
+		return Branch{}, err
+	}
+	return Branch{Pos: pos, Src: c.val, Cond: expr}, nil
+}
+
+// action parses {{operand | filter args…}}, starting after the opening braces.
+func (p *parser) action(pos int) (*ActionNode, error) {
+	n := &ActionNode{Pos: pos}
+	switch op := p.next(); op.typ {
+	case itemIdent:
+		n.Field = op.val
+	case itemString, itemNumber:
+		n.Literal = op.val
+	case itemRight:
+		return nil, &Error{Pos: pos, Msg: "empty action"}
+	default:
+		return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("expected field or literal, found %s", describe(op))}
+	}
+	for p.peek().typ == itemPipe {
+		p.next()
+		name, err := p.expect(itemIdent, "after |")
+		if err != nil {
+			return nil, err
+		}
+		want, ok := filterArgs[name.val]
+		if !ok {
+			return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("unknown filter %q", name.val)}
+		}
+		f := Filter{Pos: name.pos, Name: name.val}
+		for t := p.peek().typ; t == itemString || t == itemNumber; t = p.peek().typ {
+			f.Args = append(f.Args, p.next().val)
+		}
+		if len(f.Args) != want {
+			return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("filter %s takes %d argument(s), got %d", f.Name, want, len(f.Args))}
+		}
+		if f.Name == "fmt" {
+			if v := countVerbs(f.Args[0]); v != 1 {
+				return nil, &Error{Pos: name.pos, Msg: fmt.Sprintf("fmt format %q must contain exactly one verb, has %d", f.Args[0], v)}
+			}
+		}
+		n.Filters = append(n.Filters, f)
+	}
+	if _, err := p.expect(itemRight, "to close action"); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// countVerbs counts the fmt verbs in format, ignoring %%.
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func describe(it item) string {
+	switch it.typ {
+	case itemIdent, itemNumber:
+		return fmt.Sprintf("%s %q", it.typ, it.val)
+	case itemString:
+		return fmt.Sprintf("string %q", it.val)
+	}
+	return it.typ.String()
+}
@@ -0,0 +1,69 @@
+// Package tmpl implements the template language of registry commands.  A
+// template is ordinary command text with actions in double braces:
+//
+//	{{query}}                      the field value, shell-quoted
+//	{{ctx | fmt "-C %d"}}          the value formatted, omitted when unset
+//	{{header | fmt "-H %s"}}       formatted once per entry of a multi value
+//	{{paths | join ","}}           the entries of a multi value as one word
+//	{{dir | default "."}}          a fallback for an empty value
+//	{{frag | raw}}                 the value inserted without shell quoting
+//	{{if ignore}}-i{{end}}         text included when a condition holds
+//	{{if user}}{{user}}@{{else}}root@{{end}}
+//	{{if a}}…{{else if b and c != x}}…{{else}}…{{end}}
+//	{{"{{"}}                       a literal, inserted verbatim
+//	{{/* a comment */}}
+//
+// Conditions use the language of package cond, the same one showIf uses.
+// Filters apply left to right:
+//
+//	default "v"   replace an empty value with v
+//	join "sep"    join the entries of a multi value into a single value
+//	raw           do not shell-quote the value
+//	fmt "f"       substitute the quoted value into the fmt format f, which
+//	              must contain exactly one verb; repeated per multi entry
+//	              and producing nothing for an empty value
+//
+// Every string value substituted into the command is quoted for the target
+// shell unless raw is applied, so user input can never break out of its
+// argument; values of type Glob keep their glob metacharacters unquoted.  Literals and template text are never quoted.  Actions whose
+// value is missing, an empty string or an empty list render nothing.  Runs
+// of whitespace in template text collapse to a single space; substituted
+// values are never altered.
+package tmpl
+
+import (
+	"fmt"
+
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// Error is a template syntax error at a byte offset of the source.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("template: %s at offset %d", e.Msg, e.Pos)
+}
+
+// Render parses src and executes it with values, quoting string values for
+// the given shell.
+func Render(src string, values map[string]interface{}, sh shellquote.Shell) (string, error) {
+	t, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(values, sh), nil
+}
+
+// Keys returns the field keys referenced by src, in order of first
+// appearance, including those used in conditions.  It returns nil when src
+// does not parse.
+func Keys(src string) []string {
+	t, err := Parse(src)
+	if err != nil {
+		return nil
+	}
+	return t.Keys()
+}
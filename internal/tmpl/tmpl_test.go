@@ -0,0 +1,198 @@
+package tmpl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+var values = map[string]interface{}{
+	"query":   "it's a $(trap)",
+	"dir":     ".",
+	"glob":    "*.go",
+	"ignore":  true,
+	"word":    false,
+	"ctx":     3,
+	"zero":    0,
+	"empty":   "",
+	"multi":   "two\nlines",
+	"frag":    "-x -y",
+	"float":   0.5,
+	"spaced":  "a  b",
+	"command": "`id`",
+	"user":    "bob",
+	"host":    "example.com",
+	"method":  "POST",
+	"header":  []string{"Accept: */*", "X-Id:1"},
+	"paths":   []string{"a b", "c"},
+	"none":    []string{},
+	"pattern": Glob("my logs/*.log"),
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		sh   shellquote.Shell
+		want string
+	}{
+		// Plain substitution and quoting.
+		{"echo {{query}}", shellquote.Sh, `echo 'it'\''s a $(trap)'`},
+		{"echo {{query}}", shellquote.Fish, `echo 'it\'s a $(trap)'`},
+		{"echo {{multi}}", shellquote.Bash, `echo $'two\nlines'`},
+		{"echo {{multi}}", shellquote.Sh, "echo 'two\nlines'"},
+		{"echo {{spaced}}", shellquote.Sh, "echo 'a  b'"},
+		{"echo {{command}}", shellquote.Sh, "echo '`id`'"},
+		{"echo {{ ctx }} {{float}} {{ignore}} {{word}}", shellquote.Sh, "echo 3 0.5 true false"},
+		{"echo    {{missing}}   end", shellquote.Sh, "echo end"},
+		{"echo {{empty}} {{none}} end", shellquote.Sh, "echo end"},
+		{"tar -czf out.tgz {{paths}}", shellquote.Sh, "tar -czf out.tgz 'a b' c"},
+		{"du -sh {{pattern}}", shellquote.Sh, "du -sh 'my logs/'*.log"},
+		{`x {{pattern | fmt "-p %s"}} {{pattern | raw}}`, shellquote.Sh, "x -p 'my logs/'*.log my logs/*.log"},
+
+		// Filters.
+		{`rg {{glob | fmt "-g %s"}} {{empty | fmt "-g %s"}} x`, shellquote.Sh, `rg -g '*.go' x`},
+		{`grep {{ctx | fmt "-C %d"}} {{float | fmt "-i %g"}}`, shellquote.Sh, "grep -C 3 -i 0.5"},
+		{`curl {{header | fmt "-H %s"}} {{none | fmt "-H %s"}} url`, shellquote.Sh, "curl -H 'Accept: */*' -H X-Id:1 url"},
+		{`ls {{empty | default "."}} {{dir | default "/"}}`, shellquote.Sh, "ls . ."},
+		{`ls {{missing | default "my dir"}}`, shellquote.Sh, "ls 'my dir'"},
+		{`x {{none | default "a" | fmt "-p %s"}}`, shellquote.Sh, "x -p a"},
+		{`x {{none | fmt "-p %s" | default "-q"}}`, shellquote.Sh, "x -q"},
+		{`x {{paths | join ","}}`, shellquote.Sh, "x 'a b,c'"},
+		{`x {{paths | fmt "-p%s" | join ","}}`, shellquote.Sh, "x -p'a b',-pc"},
+		{`x {{paths | join ":" | fmt "--path=%s"}}`, shellquote.Sh, "x --path='a b:c'"},
+		{"run {{frag | raw}} {{frag}}", shellquote.Sh, "run -x -y '-x -y'"},
+		{`run {{frag | raw | fmt "[%s]"}}`, shellquote.Sh, "run [-x -y]"},
+		{`x {{ctx | fmt "%d%%"}}`, shellquote.Sh, "x 3%"},
+
+		// Conditionals.
+		{"rg {{if ignore}}-i{{end}} {{if word}}-w{{end}} x", shellquote.Sh, "rg -i x"},
+		{"ssh {{if user}}{{user}}@{{end}}{{host}}", shellquote.Sh, "ssh bob@example.com"},
+		{"ssh {{if nobody}}{{nobody}}@{{end}}{{host}}", shellquote.Sh, "ssh example.com"},
+		{"x {{if word}}a{{else}}b{{end}}", shellquote.Sh, "x b"},
+		{"x {{if word}}a{{else if zero}}b{{else if ctx}}c{{else}}d{{end}}", shellquote.Sh, "x c"},
+		{"x {{if method != GET and not word}}--data{{end}}", shellquote.Sh, "x --data"},
+		{"x {{if method = 'GET'}}get{{else}}{{method}}{{end}}", shellquote.Sh, "x POST"},
+		{"x {{if header}}{{if ignore}}-H -i{{end}}{{end}}", shellquote.Sh, "x -H -i"},
+		{"x {{if none}}-n{{end}}  {{if method = '}}'}}y{{end}}", shellquote.Sh, "x"},
+
+		// Literals, escaping and comments.
+		{`echo {{"{{"}}x{{"}}"}}`, shellquote.Sh, "echo {{x}}"},
+		{"echo {{`a b`}} {{42}}", shellquote.Sh, "echo a b 42"},
+		{`echo {{ "tab\tsep" }}`, shellquote.Sh, "echo tab\tsep"},
+		{"echo {{/* ignored {{ }} */}}done", shellquote.Sh, "echo done"},
+		{"echo } { }}", shellquote.Sh, "echo } { }}"},
+	}
+	for _, c := range cases {
+		got, err := Render(c.tmpl, values, c.sh)
+		if err != nil {
+			t.Errorf("Render(%q): %v", c.tmpl, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Render(%q, %v) = %q, want %q", c.tmpl, c.sh, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		pos  int
+		msg  string
+	}{
+		{"echo {{query", 5, "unclosed action"},
+		{"echo {{/* x", 5, "unclosed comment"},
+		{"echo {{}}", 5, "empty action"},
+		{"echo {{|x}}", 7, "expected field or literal"},
+		{"echo {{q | }}", 11, "expected identifier after |"},
+		{"echo {{q | upper}}", 11, `unknown filter "upper"`},
+		{"echo {{q | fmt}}", 11, "filter fmt takes 1 argument(s), got 0"},
+		{`echo {{q | raw "x"}}`, 11, "filter raw takes 0 argument(s), got 1"},
+		{`echo {{q | fmt "-p"}}`, 11, "exactly one verb"},
+		{`echo {{q | fmt "%s %s"}}`, 11, "exactly one verb"},
+		{`echo {{q "x"}}`, 9, "expected }} to close action"},
+		{`echo {{"abc}}`, 7, "unterminated or malformed string"},
+		{"echo {{q ; }}", 9, "unexpected ';'"},
+		{"{{if x}}a", 0, "unclosed {{if}}"},
+		{"{{if x}}a{{else}}b", 0, "unclosed {{if}}"},
+		{"{{if}}a{{end}}", 4, "missing condition"},
+		{"{{if x ==}}a{{end}}", 9, "condition: expected value after comparison"},
+		{"{{if (x}}a{{end}}", 7, "condition: expected )"},
+		{"a{{end}}", 1, "unexpected {{end}}"},
+		{"a{{else}}", 1, "unexpected {{else}}"},
+		{"{{if x}}a{{else}}b{{else}}c{{end}}", 18, "unexpected {{else}} after {{else}}"},
+		{"{{if x}}a{{end x}}", 15, "expected }} after end"},
+		{"{{if x}}a{{else y}}b{{end}}", 16, "expected }} after else"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.tmpl)
+		var te *Error
+		if !errors.As(err, &te) {
+			t.Errorf("Parse(%q) error = %v, want *Error", c.tmpl, err)
+			continue
+		}
+		if te.Pos != c.pos || !strings.Contains(te.Msg, c.msg) {
+			t.Errorf("Parse(%q) = %d %q, want %d %q", c.tmpl, te.Pos, te.Msg, c.pos, c.msg)
+		}
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	tp := MustParse(`a {{if x}}{{y | fmt "-y %s"}}{{else if z}}b{{else}}{{"c"}}{{end}}`)
+	if len(tp.Root) != 2 {
+		t.Fatalf("root has %d nodes, want 2", len(tp.Root))
+	}
+	if n, ok := tp.Root[0].(*TextNode); !ok || n.Text != "a " {
+		t.Errorf("root[0] = %#v, want text %q", tp.Root[0], "a ")
+	}
+	n, ok := tp.Root[1].(*IfNode)
+	if !ok {
+		t.Fatalf("root[1] = %#v, want *IfNode", tp.Root[1])
+	}
+	if n.Pos != 2 || len(n.Branches) != 2 || n.Branches[0].Src != "x" || n.Branches[1].Src != "z" {
+		t.Errorf("if node = %+v", n)
+	}
+	act, ok := n.Branches[0].Body[0].(*ActionNode)
+	if !ok || act.Field != "y" || !reflect.DeepEqual(act.Filters, []Filter{{Pos: 16, Name: "fmt", Args: []string{"-y %s"}}}) {
+		t.Errorf("branch action = %#v", n.Branches[0].Body[0])
+	}
+	if lit, ok := n.Else[0].(*ActionNode); !ok || lit.Field != "" || lit.Literal != "c" {
+		t.Errorf("else body = %#v", n.Else)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	if _, err := Render("{{if x}}", nil, shellquote.Sh); err == nil {
+		t.Fatal("expected an error for an unclosed if")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	got := Keys(`rg {{if ignore}}-i{{end}} {{glob | fmt "-g %s"}} {{query | raw}} {{if ignore or tool=rg}}x{{else if z}}{{"lit"}}{{end}}`)
+	want := []string{"ignore", "glob", "query", "tool", "z"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+	if got := Keys("{{if"); got != nil {
+		t.Fatalf("Keys(invalid) = %v, want nil", got)
+	}
+}
+
+func TestExecuteSpans(t *testing.T) {
+	tp := MustParse(`rg {{if ignore}}-i{{end}} {{ctx | fmt "-C %d"}} {{query}}  `)
+	cmd, spans := tp.ExecuteSpans(map[string]interface{}{"ignore": true, "ctx": 3, "query": "a b"}, shellquote.Sh)
+	if cmd != "rg -i -C 3 'a b'" {
+		t.Fatalf("command = %q", cmd)
+	}
+	var got []string
+	for _, sp := range spans {
+		got = append(got, sp.Field+"="+cmd[sp.Start:sp.End])
+	}
+	want := []string{"=rg ", "ignore=-i", "= ", "ctx=-C 3", "= ", "query='a b'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("spans = %q, want %q", got, want)
+	}
+}
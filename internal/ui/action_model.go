@@ -3,13 +3,16 @@ package ui
 import (
     "fmt"
     "io"
-    "sort"
     "strconv"
     "strings"
+    "unicode"
 
     "github.com/BlackOrder/complete-command/internal/config"
-    "github.com/BlackOrder/complete-command/internal/detect"
+    "github.com/BlackOrder/complete-command/internal/frecency"
+    "github.com/BlackOrder/complete-command/internal/history"
     "github.com/BlackOrder/complete-command/internal/registry"
+    "github.com/BlackOrder/complete-command/internal/render"
+    "github.com/BlackOrder/complete-command/internal/shellquote"
 
     "github.com/charmbracelet/bubbles/list"
     "github.com/charmbracelet/bubbles/textinput"
@@ -20,30 +23,85 @@ import (
 // actionModel is a generic form for building commands defined in the registry.
 // It dynamically constructs input fields and list items based on the action's
 // field definitions. Supported field types include string, path, bool, int,
-// float, enum, and multi. On completion the model produces a shell command
-// constructed from the selected tool and populated field values.
+// float, duration, size, enum, and multi. On completion the model produces a
+// shell command constructed from the selected tool and populated field values.
 //
-// The model uses TAB to cycle between text inputs and the list of option
-// toggles. Ctrl+T cycles through available tools. The list displays boolean,
-// numeric and enum fields with their current values and a "Build & Insert"
-// entry to finalize the command. Preferences for a selected tool are
-// persisted using the provided config pointer and action ID.
+// Every field is a row of the form, in the order the registry declares them,
+// followed by the static entries. TAB and shift+TAB cycle through the rows and
+// up/down move between them; the text input of the current row has the
+// focus. Ctrl+T cycles through available tools and Ctrl+G toggles a panel
+// explaining every word of the command. Ctrl+R runs the command and shows its
+// output, from where the user can return to the form, adjust fields and run
+// it again. When no tool is installed, Ctrl+P emits the command installing
+// the current one instead. Boolean, numeric and enum rows display their
+// current values and a "Build & Insert" entry finalizes the command.
+// Preferences for a selected tool are persisted using the provided config
+// pointer and action ID.
 type actionModel struct {
     action  registry.Action
     tools   []string
     toolIdx int
 
-    // input fields keyed by field key for strings, paths and multi entries.
+    // input fields keyed by field key for strings, paths, numbers and multi
+    // entries.
     strInputs map[string]*textinput.Model
+    // list editors for multi fields; their input lives in strInputs.
+    multi map[string]*multiField
+    // completion and browsing for path fields; their input lives in strInputs.
+    paths map[string]*pathField
     // boolean fields as list items
     boolItems []boolFieldItem
-    // numeric fields: int and float
-    intItems   []intFieldItem
-    floatItems []floatFieldItem
+    // numeric fields: int, float, duration and size, typed in their
+    // input in strInputs or stepped with +/-
+    numberItems []numberFieldItem
     // enum fields
     enumItems []enumFieldItem
-    // keep order of items for display: combination of bool, int/float, enum and build.
-    list list.Model
+    // fields holds a row for every field in declaration order; rows holds
+    // the visible ones followed by the static entries, and cursor is the
+    // index of the current row.
+    fields   []list.Item
+    rows     []list.Item
+    cursor   int
+    delegate actionItemDelegate
+
+    // visible records which fields are currently shown according to their
+    // showIf conditions; hidden fields are skipped and omitted from the command.
+    visible map[string]bool
+
+    // errors maps field keys to validation messages shown inline. It is
+    // filled when a build is attempted and kept current afterwards; the list
+    // delegate shares the map to highlight offending options.
+    errors    map[string]string
+    attempted bool
+
+    // shell selects the quoting dialect for substituted values.
+    shell shellquote.Shell
+
+    // explain shows the explain panel, which breaks the preview down into
+    // its words and what each of them does.
+    explain bool
+
+    // run is the last command started with Ctrl+R; while showRun is set
+    // its output is shown instead of the form.
+    run     *runState
+    showRun bool
+    // width and height are the terminal size, used to size the output.
+    width, height int
+
+    // confirm holds a dangerous command awaiting confirmation, shown
+    // instead of the form; refused explains why the policy refused the
+    // last command.
+    confirm *confirmState
+    refused string
+
+    // missing is set when none of the action's tools is installed; the
+    // form then offers to install the current tool with pkgManager, the
+    // host's package manager.
+    missing    bool
+    pkgManager string
+
+    // notice is a message for the user, cleared on the next key.
+    notice string
 
     // final command after building
     final   string
@@ -51,6 +109,15 @@ type actionModel struct {
     prefKey string
 }
 
+// textFieldItem is the row of a string, path or multi field, whose value is
+// edited in its text input.
+type textFieldItem struct {
+    key   string
+    label string
+}
+
+func (t textFieldItem) FilterValue() string { return t.label }
+
 // boolFieldItem represents a toggleable boolean option in a dynamic action.
 type boolFieldItem struct {
     key   string
@@ -60,27 +127,62 @@ type boolFieldItem struct {
 
 func (b boolFieldItem) FilterValue() string { return b.label }
 
-// intFieldItem represents an integer option that can be increased or decreased.
-type intFieldItem struct {
+// numberFieldItem represents an int, float, duration or size option. Its
+// value is typed in its text input or stepped with +/- by the field's step.
+type numberFieldItem struct {
     key   string
     label string
-    val   *int
-    min   *float64
-    max   *float64
+    field registry.Field
 }
 
-func (i intFieldItem) FilterValue() string { return i.label }
+func (n numberFieldItem) FilterValue() string { return n.label }
 
-// floatFieldItem represents a float option that can be modified via +/- keys.
-type floatFieldItem struct {
-    key   string
-    label string
-    val   *float64
-    min   *float64
-    max   *float64
+// value converts the text typed for the field to the field's type. Text
+// that does not parse is returned as is, for validation to report.
+func (n numberFieldItem) value(text string) interface{} {
+    if v, err := n.field.ParseValue(text); err == nil && strings.TrimSpace(text) != "" {
+        return v
+    }
+    return text
+}
+
+// negative reports whether the field takes negative values: an int or
+// float field whose Min is unset or below zero.
+func (n numberFieldItem) negative() bool {
+    numeric := n.field.Type == "int" || n.field.Type == "float"
+    return numeric && (n.field.Min == nil || *n.field.Min < 0)
+}
+
+// accepts reports whether the runes may be typed into the field at cursor
+// position pos: digits, a leading minus sign for fields taking negative
+// values, a decimal point for floats and unit letters for durations and
+// sizes.
+func (n numberFieldItem) accepts(runes []rune, pos int) bool {
+    for i, r := range runes {
+        switch {
+        case unicode.IsDigit(r):
+        case r == '-' && i == 0 && pos == 0 && n.negative():
+        case r == '.' && n.field.Type != "int":
+        case unicode.IsLetter(r) && (n.field.Type == "duration" || n.field.Type == "size"):
+        default:
+            return false
+        }
+    }
+    return true
 }
 
-func (f floatFieldItem) FilterValue() string { return f.label }
+// formatNumber returns the text shown for the value of a numeric field.
+func formatNumber(v interface{}) string {
+    switch n := v.(type) {
+    case int:
+        return strconv.Itoa(n)
+    case float64:
+        return strconv.FormatFloat(n, 'f', -1, 64)
+    case string:
+        return n
+    }
+    return ""
+}
 
 // enumFieldItem represents an enumeration option cycling through choices.
 type enumFieldItem struct {
@@ -92,158 +194,276 @@ type enumFieldItem struct {
 
 func (e enumFieldItem) FilterValue() string { return e.label }
 
-// staticItem is used for the final "Build & Insert" entry.
+// staticItem is used for the final "Run" and "Build & Insert" entries.
 type staticItem struct {
     label string
 }
 
+// Labels of the static list entries.
+const (
+    runLabel   = "Run"
+    buildLabel = "Build & Insert"
+)
+
 func (s staticItem) FilterValue() string { return s.label }
 
 // NewActionModel constructs a new dynamic action model for the given action.
 // It uses the provided configuration to reorder tool candidates based on
-// previous preferences. Fields are initialised with defaults when provided.
+// previous preferences. Fields marked remember start with the value last
+// used; the others are initialised with defaults when provided.
 func NewActionModel(action registry.Action, cfg *config.Config) actionModel {
-    // Determine available tools by checking candidate binaries in PATH.
-    var available []string
-    for _, c := range action.Candidates {
-        if detect.Has(c) {
-            available = append(available, c)
-        }
-    }
-    if len(available) == 0 {
-        // If none are found, fallback to listing all candidates anyway; the
-        // resulting command may still be valid if user has them installed.
-        available = append(available, action.Candidates...)
-    }
-    // Reorder tools based on preference.
-    if cfg != nil && action.ID != "" {
-        if pref, ok := cfg.PreferredTool(action.ID); ok {
-            for i, t := range available {
-                if t == pref {
-                    available[0], available[i] = available[i], available[0]
-                    break
-                }
-            }
+    return NewPrefilledActionModel(action, cfg, "", render.Remembered(action, cfg))
+}
+
+// NewPrefilledActionModel is like NewActionModel but starts with tool
+// selected, when it is available, and with the saved field values in place
+// of the defaults. It is used to reopen a command from the history.
+func NewPrefilledActionModel(action registry.Action, cfg *config.Config, tool string, saved map[string]interface{}) actionModel {
+    // Determine available tools, preferred tool first.
+    available := render.Tools(action, cfg)
+    toolIdx := 0
+    for i, t := range available {
+        if t == tool {
+            toolIdx = i
+            break
         }
     }
+    defaults := render.Prefill(action, saved)
     // Prepare input maps and list items.
     strInputs := make(map[string]*textinput.Model)
+    multi := make(map[string]*multiField)
+    paths := make(map[string]*pathField)
     var boolItems []boolFieldItem
-    var intItems []intFieldItem
-    var floatItems []floatFieldItem
+    var numberItems []numberFieldItem
     var enumItems []enumFieldItem
+    var fields []list.Item
     // Create inputs based on field definitions.
     for _, f := range action.Fields {
         label := f.Label
         if label == "" {
             label = f.Key
         }
+        def := defaults[f.Key]
         switch f.Type {
-        case "string", "path", "multi":
+        case "string", "path":
             ti := textinput.New()
             if f.Placeholder != "" {
                 ti.Placeholder = f.Placeholder
             }
-            if defStr, ok := f.Default.(string); ok {
+            if defStr, ok := def.(string); ok {
                 ti.SetValue(defStr)
             }
             strInputs[f.Key] = &ti
-        case "bool":
-            defBool := false
-            if b, ok := f.Default.(bool); ok {
-                defBool = b
+            if f.Type == "path" {
+                paths[f.Key] = newPathField(f)
             }
+            fields = append(fields, textFieldItem{key: f.Key, label: label})
+        case "multi":
+            // The input types a new entry; entries are held by the editor.
+            ti := textinput.New()
+            ti.Placeholder = f.Placeholder
+            if ti.Placeholder == "" {
+                ti.Placeholder = f.Entry
+            }
+            strInputs[f.Key] = &ti
+            entries, _ := def.([]string)
+            multi[f.Key] = newMultiField(f, entries)
+            fields = append(fields, textFieldItem{key: f.Key, label: label})
+        case "bool":
             val := new(bool)
-            *val = defBool
+            *val, _ = def.(bool)
             boolItems = append(boolItems, boolFieldItem{key: f.Key, label: label, val: val})
-        case "int":
-            var defInt int
-            if f.Default != nil {
-                switch v := f.Default.(type) {
-                case int:
-                    defInt = v
-                case int64:
-                    defInt = int(v)
-                case float64:
-                    defInt = int(v)
-                }
-            }
-            val := new(int)
-            *val = defInt
-            intItems = append(intItems, intFieldItem{key: f.Key, label: label, val: val, min: f.Min, max: f.Max})
-        case "float":
-            var defF float64
-            if f.Default != nil {
-                switch v := f.Default.(type) {
-                case float32:
-                    defF = float64(v)
-                case float64:
-                    defF = v
-                case int:
-                    defF = float64(v)
-                }
-            }
-            val := new(float64)
-            *val = defF
-            floatItems = append(floatItems, floatFieldItem{key: f.Key, label: label, val: val, min: f.Min, max: f.Max})
+            fields = append(fields, boolItems[len(boolItems)-1])
+        case "int", "float", "duration", "size":
+            ti := textinput.New()
+            ti.Prompt = ""
+            ti.Placeholder = f.Placeholder
+            ti.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("81"))
+            ti.SetValue(formatNumber(def))
+            strInputs[f.Key] = &ti
+            numberItems = append(numberItems, numberFieldItem{key: f.Key, label: label, field: f})
+            fields = append(fields, numberItems[len(numberItems)-1])
         case "enum":
             idx := new(int)
-            defIdx := 0
-            // Determine default index from Default (which may be string)
-            if f.Default != nil {
-                switch v := f.Default.(type) {
-                case string:
-                    for i, c := range f.Choices {
-                        if c == v {
-                            defIdx = i
-                            break
-                        }
-                    }
+            for i, c := range f.Choices {
+                if c == def {
+                    *idx = i
+                    break
                 }
             }
-            *idx = defIdx
             enumItems = append(enumItems, enumFieldItem{key: f.Key, label: label, choices: f.Choices, idx: idx})
+            fields = append(fields, enumItems[len(enumItems)-1])
+        }
+    }
+    // Rows are populated by refreshVisibility below.
+    errs := make(map[string]string)
+    // Create the model and hide fields whose showIf does not hold.
+    m := actionModel{
+        action:      action,
+        tools:       available,
+        toolIdx:     toolIdx,
+        strInputs:   strInputs,
+        multi:       multi,
+        paths:       paths,
+        boolItems:   boolItems,
+        numberItems: numberItems,
+        enumItems:   enumItems,
+        fields:      fields,
+        delegate:    actionItemDelegate{errors: errs, inputs: strInputs},
+        errors:      errs,
+        shell:       shellquote.FromEnv(),
+        cfg:         cfg,
+        prefKey:     action.ID,
+    }
+    if !render.Usable(action) {
+        m.missing, m.pkgManager = true, render.PackageManager()
+    }
+    m.refreshVisibility()
+    return m
+}
+
+// refreshVisibility re-evaluates showIf conditions against the current tool
+// and field values. Hidden fields are removed from the rows, keeping the
+// cursor on the same row if it is still shown and in place otherwise, and
+// the text input of the current row gets the focus.
+func (m *actionModel) refreshVisibility() {
+    m.visible = m.action.Visible(m.tools[m.toolIdx], m.values())
+    var selected string
+    if m.cursor < len(m.rows) {
+        selected = itemKey(m.rows[m.cursor])
+    }
+    rows := make([]list.Item, 0, len(m.fields)+2)
+    for _, it := range m.fields {
+        if m.visible[itemKey(it)] {
+            rows = append(rows, it)
         }
     }
-    // Build list items: bool, int, float, enum and final build item.
-    var items []list.Item
-    for _, b := range boolItems {
-        items = append(items, b)
-    }
-    for _, i := range intItems {
-        items = append(items, i)
-    }
-    for _, f := range floatItems {
-        items = append(items, f)
-    }
-    for _, e := range enumItems {
-        items = append(items, e)
-    }
-    items = append(items, staticItem{label: "Build & Insert"})
-    l := list.New(items, actionItemDelegate{}, 0, 0)
-    l.SetShowStatusBar(false)
-    l.SetFilteringEnabled(false)
-    // Create and return the model.
-    return actionModel{
-        action:    action,
-        tools:     available,
-        toolIdx:   0,
-        strInputs: strInputs,
-        boolItems: boolItems,
-        intItems:  intItems,
-        floatItems: floatItems,
-        enumItems: enumItems,
-        list:      l,
-        cfg:       cfg,
-        prefKey:   action.ID,
+    m.rows = append(rows, staticItem{label: runLabel}, staticItem{label: buildLabel})
+    for i, it := range m.rows {
+        if itemKey(it) == selected {
+            m.cursor = i
+            break
+        }
+    }
+    if m.cursor >= len(m.rows) {
+        m.cursor = len(m.rows) - 1
+    }
+    for k, ti := range m.strInputs {
+        if k == itemKey(m.rows[m.cursor]) {
+            ti.Focus()
+        } else if ti.Focused() {
+            ti.Blur()
+        }
+    }
+}
+
+// current returns the row under the cursor.
+func (m actionModel) current() list.Item {
+    return m.rows[m.cursor]
+}
+
+// focused returns the text input of the current row, or nil when the row
+// has none.
+func (m actionModel) focused() *textinput.Model {
+    switch it := m.current().(type) {
+    case textFieldItem:
+        return m.strInputs[it.key]
+    case numberFieldItem:
+        return m.strInputs[it.key]
+    }
+    return nil
+}
+
+// step moves the value of a numeric row by n steps of its field. An empty
+// value steps from the field's default.
+func (m actionModel) step(it numberFieldItem, n int) {
+    ti := m.strInputs[it.key]
+    var v interface{} = it.field.DefaultValue()
+    if strings.TrimSpace(ti.Value()) != "" {
+        v = it.value(ti.Value())
+    }
+    ti.SetValue(formatNumber(it.field.Stepped(v, n)))
+    ti.CursorEnd()
+}
+
+// move moves the cursor by delta rows. With wrap set it cycles past the
+// first and last rows; otherwise it stops there.
+func (m *actionModel) move(delta int, wrap bool) {
+    n := len(m.rows)
+    next := m.cursor + delta
+    switch {
+    case wrap:
+        next = (next%n + n) % n
+    case next < 0:
+        next = 0
+    case next >= n:
+        next = n - 1
     }
+    m.cursor = next
+}
+
+// itemKey returns the field key of a list item, or its label for static items.
+func itemKey(it list.Item) string {
+    switch v := it.(type) {
+    case textFieldItem:
+        return v.key
+    case boolFieldItem:
+        return v.key
+    case numberFieldItem:
+        return v.key
+    case enumFieldItem:
+        return v.key
+    case staticItem:
+        return v.label
+    }
+    return ""
 }
 
 // Init implements tea.Model. No asynchronous initialization is required.
 func (m actionModel) Init() tea.Cmd { return nil }
 
-// Update processes incoming messages, updating focused inputs, toggles and selection.
+// Update processes incoming messages, updating focused inputs, toggles and
+// selection. Field visibility is re-evaluated after every message so fields
+// appear and disappear as the tool and values change.
 func (m actionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+    next, cmd := m.update(msg)
+    if am, ok := next.(actionModel); ok {
+        am.refreshVisibility()
+        if am.attempted {
+            am.validate()
+        }
+        return am, cmd
+    }
+    return next, cmd
+}
+
+func (m actionModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+    switch msg := msg.(type) {
+    case runEventMsg:
+        return m.updateRunEvent(msg)
+    case tea.WindowSizeMsg:
+        m.width, m.height = msg.Width, msg.Height
+        m.resizeRun()
+    case tea.KeyMsg:
+        if m.confirm != nil {
+            return m.updateConfirm(msg)
+        }
+        if m.showRun {
+            return m.updateRun(msg)
+        }
+        m.refused, m.notice = "", ""
+    }
+    // A focused multi or path field consumes the keys of its editor first.
+    if km, ok := msg.(tea.KeyMsg); ok {
+        if it, ok := m.current().(textFieldItem); ok {
+            if mf := m.multi[it.key]; mf != nil && mf.handleKey(km.String(), m.strInputs[it.key]) {
+                return m, nil
+            }
+            if pf := m.paths[it.key]; pf != nil && pf.handleKey(km.String(), m.strInputs[it.key]) {
+                return m, nil
+            }
+        }
+    }
     switch msg := msg.(type) {
     case tea.KeyMsg:
         switch msg.String() {
@@ -255,190 +475,159 @@ func (m actionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 m.toolIdx = (m.toolIdx + 1) % len(m.tools)
             }
             return m, nil
-        case "tab":
-            // Cycle focus between text inputs and list.
-            focusedKey := ""
-            for k, ti := range m.strInputs {
-                if ti.Focused() {
-                    focusedKey = k
-                    break
-                }
+        case "ctrl+g":
+            // Not Ctrl+E, which moves to the end of the focused text input.
+            m.explain = !m.explain
+            return m, nil
+        case "ctrl+r":
+            return m.startRun()
+        case "ctrl+x":
+            return m.forget()
+        case "ctrl+p":
+            if cmd, ok := m.installCommand(); ok && m.missing {
+                return m.guardCommand(cmd, installMode)
             }
-            if focusedKey != "" {
-                // Blur current and focus next input; order is deterministic by map iteration in Go
-                blurNext := false
-                var nextKey string
-                for k, ti := range m.strInputs {
-                    if k == focusedKey {
-                        blurNext = true
-                        ti.Blur()
-                        continue
-                    }
-                    if blurNext && nextKey == "" {
-                        nextKey = k
-                        break
-                    }
-                }
-                if nextKey != "" {
-                    m.strInputs[nextKey].Focus()
-                } else {
-                    m.list.Select(0)
-                }
-            } else {
-                // If list is focused, cycle back to first input.
-                for _, ti := range m.strInputs {
-                    ti.Focus()
-                    break
-                }
+            return m, nil
+        case "tab", "shift+tab":
+            // Cycle through the rows; the focus follows the cursor.
+            delta := 1
+            if msg.String() == "shift+tab" {
+                delta = -1
+            }
+            m.move(delta, true)
+            return m, nil
+        case "up", "down":
+            delta := 1
+            if msg.String() == "up" {
+                delta = -1
             }
+            m.move(delta, false)
+            return m, nil
         case "left", "right":
             // Left/right are unused for tool selection; ignore.
         case "+":
-            // Increment numeric values when selected.
-            idx := m.list.Index()
-            if idx >= 0 && idx < len(m.boolItems)+len(m.intItems)+len(m.floatItems)+len(m.enumItems) {
-                offset := len(m.boolItems)
-                // Int items
-                if idx >= offset && idx < offset+len(m.intItems) {
-                    intIdx := idx - offset
-                    v := m.intItems[intIdx].val
-                    *v = *v + 1
-                } else {
-                    offset += len(m.intItems)
-                    if idx >= offset && idx < offset+len(m.floatItems) {
-                        floatIdx := idx - offset
-                        *m.floatItems[floatIdx].val += 1.0
-                    }
-                }
+            // Step numeric values up, stopping at Max.
+            if it, ok := m.current().(numberFieldItem); ok {
+                m.step(it, 1)
+                return m, nil
             }
         case "-", "_":
-            // Decrement numeric values when selected.
-            idx := m.list.Index()
-            if idx >= 0 && idx < len(m.boolItems)+len(m.intItems)+len(m.floatItems)+len(m.enumItems) {
-                offset := len(m.boolItems)
-                if idx >= offset && idx < offset+len(m.intItems) {
-                    intIdx := idx - offset
-                    if m.intItems[intIdx].min != nil {
-                        minVal := int(*m.intItems[intIdx].min)
-                        if *m.intItems[intIdx].val > minVal {
-                            *m.intItems[intIdx].val--
-                        }
-                    } else if *m.intItems[intIdx].val > 0 {
-                        *m.intItems[intIdx].val--
-                    }
-                } else {
-                    offset += len(m.intItems)
-                    if idx >= offset && idx < offset+len(m.floatItems) {
-                        floatIdx := idx - offset
-                        if m.floatItems[floatIdx].min != nil {
-                            minVal := *m.floatItems[floatIdx].min
-                            if *m.floatItems[floatIdx].val > minVal {
-                                *m.floatItems[floatIdx].val -= 0.1
-                            }
-                        } else {
-                            *m.floatItems[floatIdx].val -= 0.1
-                        }
-                    }
+            // Step numeric values down, stopping at Min. A minus typed at
+            // the start of a value that may be negative is its sign.
+            if it, ok := m.current().(numberFieldItem); ok {
+                ti := m.strInputs[it.key]
+                if msg.String() == "-" && it.negative() && ti.Position() == 0 && !strings.HasPrefix(ti.Value(), "-") {
+                    break
                 }
+                m.step(it, -1)
+                return m, nil
             }
         case "enter":
-            // If a text input is focused, pressing enter builds and exits.
-            for _, ti := range m.strInputs {
-                if ti.Focused() {
-                    if m.cfg != nil && m.prefKey != "" {
-                        m.cfg.SetPreference(m.prefKey, m.tools[m.toolIdx])
-                        _ = config.Save(m.cfg)
+            // On a text or numeric field, pressing enter builds and exits.
+            switch it := m.current().(type) {
+            case textFieldItem, numberFieldItem:
+                return m.build()
+            case boolFieldItem:
+                // toggle bool
+                *it.val = !*it.val
+            case enumFieldItem:
+                // cycle enum value to next
+                if len(it.choices) > 0 {
+                    cur := *it.idx
+                    cur++
+                    if cur >= len(it.choices) {
+                        cur = 0
                     }
-                    m.final = m.buildCommand()
-                    return m, tea.Quit
+                    *it.idx = cur
                 }
-            }
-            idx := m.list.Index()
-            // Determine which section this index belongs to.
-            if idx >= 0 && idx < len(m.boolItems)+len(m.intItems)+len(m.floatItems)+len(m.enumItems)+1 {
-                limit := len(m.boolItems)
-                if idx < limit {
-                    // toggle bool
-                    *m.boolItems[idx].val = !*m.boolItems[idx].val
-                } else {
-                    idx -= limit
-                    limit = len(m.intItems)
-                    if idx < limit {
-                        // numeric item; enter does nothing for ints
-                    } else {
-                        idx -= limit
-                        limit = len(m.floatItems)
-                        if idx < limit {
-                            // floats: no action on enter
-                        } else {
-                            idx -= limit
-                            limit = len(m.enumItems)
-                            if idx < limit {
-                                // cycle enum value to next
-                                e := &m.enumItems[idx]
-                                if len(e.choices) > 0 {
-                                    cur := *e.idx
-                                    cur++
-                                    if cur >= len(e.choices) {
-                                        cur = 0
-                                    }
-                                    *e.idx = cur
-                                }
-                            } else {
-                                // final build item selected; build command and exit
-                                if m.cfg != nil && m.prefKey != "" {
-                                    m.cfg.SetPreference(m.prefKey, m.tools[m.toolIdx])
-                                    _ = config.Save(m.cfg)
-                                }
-                                cmd := m.buildCommand()
-                                m.final = cmd
-                                return m, tea.Quit
-                            }
-                        }
-                    }
+            case staticItem:
+                if it.label == runLabel {
+                    return m.startRun()
                 }
+                // final build item selected; build command and exit
+                return m.build()
             }
         }
     }
-    // Update text inputs if any of them is focused.
-    for _, ti := range m.strInputs {
-        if ti.Focused() {
-            var cmd tea.Cmd
-            *ti, cmd = ti.Update(msg)
-            return m, cmd
+    // Numeric fields only take the characters of a number.
+    if km, ok := msg.(tea.KeyMsg); ok && (km.Type == tea.KeyRunes || km.Type == tea.KeySpace) {
+        if it, ok := m.current().(numberFieldItem); ok && (km.Type == tea.KeySpace || !it.accepts(km.Runes, m.strInputs[it.key].Position())) {
+            return m, nil
         }
     }
-    // Otherwise update the list.
-    var cmd tea.Cmd
-    m.list, cmd = m.list.Update(msg)
-    return m, cmd
+    // Anything else goes to the text input of the current row.
+    if ti := m.focused(); ti != nil {
+        var cmd tea.Cmd
+        *ti, cmd = ti.Update(msg)
+        return m, cmd
+    }
+    return m, nil
 }
 
-// buildCommand assembles the command string for the selected tool and current
-// field values. It is called when exiting the model.
-func (m actionModel) buildCommand() string {
-    // Build a map of field values keyed by their keys.
+// build validates the form and, when every visible field is valid and the
+// policy lets the command through, inserts it. Otherwise the errors are
+// shown inline and the form stays open.
+func (m actionModel) build() (tea.Model, tea.Cmd) {
+    m.attempted = true
+    if !m.validate() {
+        return m, nil
+    }
+    cmd, err := m.buildCommand()
+    if err != nil {
+        // A broken registry template; the preview shows the error.
+        return m, nil
+    }
+    return m.guardCommand(cmd, insertMode)
+}
+
+// insert records the tool preference and remembered field values in the
+// config and the command in the history, stores it as the final command and
+// quits.
+func (m actionModel) insert(cmd string) (tea.Model, tea.Cmd) {
+    m.remember(true)
+    m.final = cmd
+    _ = history.Append(history.Entry{
+        Action:  m.action.ID,
+        Tool:    m.tools[m.toolIdx],
+        Values:  m.shownValues(),
+        Command: cmd,
+    })
+    _ = frecency.Visit(m.action.ID)
+    return m, tea.Quit
+}
+
+// validate checks every visible field against its registry constraints,
+// replacing the contents of m.errors. It reports whether the form is valid.
+func (m actionModel) validate() bool {
+    for k := range m.errors {
+        delete(m.errors, k)
+    }
+    for k, msg := range m.action.Validate(m.tools[m.toolIdx], m.values()) {
+        m.errors[k] = msg
+    }
+    return len(m.errors) == 0
+}
+
+// values collects the current value of every field keyed by field key,
+// regardless of visibility.
+func (m actionModel) values() map[string]interface{} {
     values := make(map[string]interface{})
     for k, ti := range m.strInputs {
         values[k] = ti.Value()
     }
+    // Multi fields hold their entries rather than the pending input.
+    for k, mf := range m.multi {
+        values[k] = mf.values()
+    }
     // Booleans
     for _, b := range m.boolItems {
         if b.val != nil {
             values[b.key] = *b.val
         }
     }
-    // Integers
-    for _, it := range m.intItems {
-        if it.val != nil {
-            values[it.key] = *it.val
-        }
-    }
-    // Floats
-    for _, fl := range m.floatItems {
-        if fl.val != nil {
-            values[fl.key] = *fl.val
-        }
+    // Numbers are typed as text.
+    for _, n := range m.numberItems {
+        values[n.key] = n.value(m.strInputs[n.key].Value())
     }
     // Enums
     for _, e := range m.enumItems {
@@ -446,116 +635,97 @@ func (m actionModel) buildCommand() string {
             values[e.key] = e.choices[*e.idx]
         }
     }
-    // Render template for selected tool.
-    template := m.action.Template[m.tools[m.toolIdx]]
-    result := ""
-    i := 0
-    for i < len(template) {
-        // Look for next placeholder "{{"...
-        if strings.HasPrefix(template[i:], "{{") {
-            end := strings.Index(template[i:], "}}")
-            if end < 0 {
-                // unmatched braces; append rest
-                result += template[i:]
-                break
-            }
-            placeholder := template[i+2 : i+end]
-            // Parse placeholder: key[?] or key|fmt or key|fmt? etc.
-            if strings.Contains(placeholder, "|") {
-                parts := strings.SplitN(placeholder, "|", 2)
-                key := parts[0]
-                format := parts[1]
-                v, ok := values[key]
-                if ok {
-                    // Use Sprintf with format specifier, trimming leading % if present.
-                    if strings.HasPrefix(format, "%") {
-                        result += fmt.Sprintf(format, v)
-                    } else {
-                        result += fmt.Sprintf(format, v)
-                    }
-                }
-            } else if strings.Contains(placeholder, "?") {
-                // Conditional placeholder: key?value
-                parts := strings.SplitN(placeholder, "?", 2)
-                key := parts[0]
-                flag := parts[1]
-                v, ok := values[key]
-                if ok {
-                    switch bv := v.(type) {
-                    case bool:
-                        if bv {
-                            result += flag
-                        }
-                    case string:
-                        if bv != "" {
-                            result += flag
-                        }
-                    case int:
-                        if bv != 0 {
-                            result += flag
-                        }
-                    case float64:
-                        if bv != 0 {
-                            result += flag
-                        }
-                    }
-                }
-            } else {
-                // Simple key replacement
-                v, ok := values[placeholder]
-                if ok {
-                    switch vv := v.(type) {
-                    case string:
-                        result += vv
-                    case bool:
-                        if vv {
-                            result += "true"
-                        } else {
-                            result += "false"
-                        }
-                    case int:
-                        result += strconv.Itoa(vv)
-                    case float64:
-                        // Convert float to string without trailing zeros.
-                        result += fmt.Sprintf("%g", vv)
-                    default:
-                        result += fmt.Sprint(vv)
-                    }
-                }
-            }
-            i += end + 2
-        } else {
-            result += string(template[i])
-            i++
+    return values
+}
+
+// shownValues returns the values of the fields that are currently visible,
+// which are the ones the built command was rendered from.
+func (m actionModel) shownValues() map[string]interface{} {
+    values := m.values()
+    for k := range values {
+        if !m.visible[k] {
+            delete(values, k)
         }
     }
-    fields := strings.Fields(result)
-    return strings.Join(fields, " ")
+    return values
+}
+
+// buildCommand assembles the command string for the selected tool and current
+// field values, quoting values for the user's shell. Values of fields hidden
+// by their showIf condition are left out, so their actions render empty. It
+// fails only when the registry template itself is invalid.
+func (m actionModel) buildCommand() (string, error) {
+    return render.Command(m.action, m.tools[m.toolIdx], m.values(), m.shell)
 }
 
 // View renders the current form state. A colourful header and instructions
-// precede the list of inputs and options. The entire view is wrapped in a
+// precede the list of inputs and options, followed by a highlighted preview
+// of the command for the current tool and values. The entire view is wrapped in a
 // rounded border to provide an app‑like feel.
 func (m actionModel) View() string {
+    if m.confirm != nil {
+        return m.viewConfirm()
+    }
+    if m.showRun {
+        return m.viewRun()
+    }
     // Colourful header with action title and current tool.
     title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")).Render(m.action.Title)
     tool := lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Render(fmt.Sprintf("Tool: %s", m.tools[m.toolIdx]))
-    header := fmt.Sprintf("%s • %s  (Ctrl+T next tool)\n", title, tool)
-    instructions := "TAB to switch fields • ENTER to toggle/build • +/- to adjust • ESC to cancel"
+    header := fmt.Sprintf("%s • %s  (Ctrl+T next tool • Ctrl+G explain • Ctrl+R run)\n", title, tool)
+    instructions := "TAB/↑/↓ to move between fields • ENTER to toggle/build • +/- to adjust • ESC to cancel"
+    if m.remembers() {
+        instructions += " • Ctrl+X to forget values"
+    }
     header += lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(instructions) + "\n\n"
-    // Render text inputs in sorted order.
-    keys := make([]string, 0, len(m.strInputs))
-    for k := range m.strInputs {
-        keys = append(keys, k)
-    }
-    sort.Strings(keys)
-    content := header
-    for _, k := range keys {
-        ti := m.strInputs[k]
-        label := lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Render(strings.Title(k) + ": ")
-        content += label + ti.View() + "\n"
-    }
-    content += "\n" + m.list.View()
+    if m.missing {
+        header += m.installHint() + "\n\n"
+    }
+    // Render the rows in declaration order, the static entries set apart.
+    var b strings.Builder
+    b.WriteString(header)
+    for i, it := range m.rows {
+        if _, ok := it.(staticItem); ok && i > 0 {
+            if _, prev := m.rows[i-1].(staticItem); !prev {
+                b.WriteString("\n")
+            }
+        }
+        m.delegate.renderRow(&b, it, i == m.cursor)
+        if t, ok := it.(textFieldItem); ok {
+            if msg, bad := m.errors[t.key]; bad {
+                b.WriteString("    " + errorStyle.Render(msg) + "\n")
+            }
+            if mf, ok := m.multi[t.key]; ok {
+                b.WriteString(mf.view(i == m.cursor))
+            }
+            if pf, ok := m.paths[t.key]; ok {
+                b.WriteString(pf.view(m.strInputs[t.key].Value(), i == m.cursor))
+            }
+        }
+    }
+    content := b.String()
+    if len(m.errors) > 0 {
+        content += "\n" + errorStyle.Render(fmt.Sprintf("Fix %d field(s) before building.", len(m.errors)))
+    }
+    // Live preview of the command that would be inserted.
+    if cmd, err := m.buildCommand(); err != nil {
+        content += "\n\n" + errorStyle.Render(fmt.Sprintf("Invalid template for %s: %v", m.tools[m.toolIdx], err))
+    } else {
+        content += "\n\n" + renderPreview(cmd)
+        if m.explain {
+            _, tokens, _ := render.Explain(m.action, m.tools[m.toolIdx], m.values(), m.shell)
+            content += "\n" + renderExplain(tokens)
+        }
+    }
+    if m.run != nil {
+        content += "\n" + previewOpStyle.Render("Last run: ") + m.run.status()
+    }
+    if m.refused != "" {
+        content += "\n" + errorStyle.Render(m.refused)
+    }
+    if m.notice != "" {
+        content += "\n" + previewOpStyle.Render(m.notice)
+    }
     // Wrap in a rounded border with padding.
     style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1)
     return style.Render(content)
@@ -564,22 +734,42 @@ func (m actionModel) View() string {
 // FinalCommand returns the constructed command after the model exits.
 func (m actionModel) FinalCommand() string { return m.final }
 
-// actionItemDelegate handles rendering of list items for actionModel. It displays
-// current values for booleans, integers, floats and enums with colour.
-type actionItemDelegate struct{}
+// errorStyle renders validation messages.
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+// actionItemDelegate handles rendering of the rows of actionModel. It displays
+// text inputs and the current values of booleans, integers, floats and enums
+// with colour. Rows with a validation error show the message.
+type actionItemDelegate struct {
+    errors map[string]string
+    inputs map[string]*textinput.Model
+}
+
+// errorSuffix returns the validation message for key, if any, formatted to
+// follow the item's label on the same line.
+func (d actionItemDelegate) errorSuffix(key string) string {
+    if msg, ok := d.errors[key]; ok {
+        return "  " + errorStyle.Render(msg)
+    }
+    return ""
+}
 
-func (d actionItemDelegate) Height() int                             { return 1 }
-func (d actionItemDelegate) Spacing() int                            { return 0 }
-func (d actionItemDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
-func (d actionItemDelegate) Render(w io.Writer, m list.Model, idx int, listItem list.Item) {
+// renderRow writes a row of the form, marking it when it is the current one.
+func (d actionItemDelegate) renderRow(w io.Writer, listItem list.Item, current bool) {
     // Colourful prefix depending on selection.
     var prefix string
-    if idx == m.Index() {
+    if current {
         prefix = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Render("> ")
     } else {
         prefix = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("  ")
     }
     switch it := listItem.(type) {
+    case textFieldItem:
+        labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+        if _, bad := d.errors[it.key]; bad {
+            labelStyle = labelStyle.Foreground(lipgloss.Color("196"))
+        }
+        fmt.Fprintf(w, "%s%s%s\n", prefix, labelStyle.Render(it.label+": "), d.inputs[it.key].View())
     case boolFieldItem:
         var stateStr string
         if it.val != nil && *it.val {
@@ -587,23 +777,18 @@ func (d actionItemDelegate) Render(w io.Writer, m list.Model, idx int, listItem
         } else {
             stateStr = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("[ ]")
         }
-        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label)
+        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label) + d.errorSuffix(it.key)
         fmt.Fprintf(w, "%s%s %s\n", prefix, stateStr, labelStr)
-    case intFieldItem:
-        val := 0
-        if it.val != nil {
-            val = *it.val
-        }
-        valStr := lipgloss.NewStyle().Foreground(lipgloss.Color("81")).Render(fmt.Sprintf("[%d]", val))
-        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label)
-        fmt.Fprintf(w, "%s%s %s\n", prefix, valStr, labelStr)
-    case floatFieldItem:
-        val := 0.0
-        if it.val != nil {
-            val = *it.val
+    case numberFieldItem:
+        bracket := lipgloss.NewStyle().Foreground(lipgloss.Color("81"))
+        ti := d.inputs[it.key]
+        // A blurred input still pads its value for the cursor.
+        val := ti.TextStyle.Render(ti.Value())
+        if ti.Focused() {
+            val = ti.View()
         }
-        valStr := lipgloss.NewStyle().Foreground(lipgloss.Color("81")).Render(fmt.Sprintf("[%.1f]", val))
-        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label)
+        valStr := bracket.Render("[") + val + bracket.Render("]")
+        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label) + d.errorSuffix(it.key)
         fmt.Fprintf(w, "%s%s %s\n", prefix, valStr, labelStr)
     case enumFieldItem:
         choice := ""
@@ -611,7 +796,7 @@ func (d actionItemDelegate) Render(w io.Writer, m list.Model, idx int, listItem
             choice = it.choices[*it.idx]
         }
         choiceStr := lipgloss.NewStyle().Foreground(lipgloss.Color("198")).Render(fmt.Sprintf("[%s]", choice))
-        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label)
+        labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(it.label) + d.errorSuffix(it.key)
         fmt.Fprintf(w, "%s%s %s\n", prefix, choiceStr, labelStr)
     case staticItem:
         labelStr := lipgloss.NewStyle().Foreground(lipgloss.Color("198")).Bold(true).Render(it.label)
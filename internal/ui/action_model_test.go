@@ -0,0 +1,75 @@
+package ui
+
+import (
+    "testing"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestNumberSign checks that a minus typed at the start of a numeric field
+// taking negative values is its sign, and steps the value down otherwise.
+func TestNumberSign(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    zero := 0.0
+    act := registry.Action{
+        ID:         "test/number",
+        Title:      "Number",
+        Candidates: []string{"sh"},
+        Template:   map[string]string{"sh": "sh {{n}} {{p}}"},
+        Fields: []registry.Field{
+            {Key: "n", Type: "int"},
+            {Key: "p", Type: "int", Min: &zero, Default: 3},
+        },
+    }
+    typ := func(m tea.Model, keys ...tea.KeyMsg) tea.Model {
+        for _, k := range keys {
+            m, _ = m.Update(k)
+        }
+        return m
+    }
+    runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+    var m tea.Model = NewActionModel(act, nil)
+    m = typ(m, tea.KeyMsg{Type: tea.KeyCtrlU}, runes("-"), runes("4"))
+    if got := m.(actionModel).strInputs["n"].Value(); got != "-4" {
+        t.Errorf("n = %q after typing -4, want -4", got)
+    }
+    m = typ(m, runes("-"))
+    if got := m.(actionModel).strInputs["n"].Value(); got != "-5" {
+        t.Errorf("n = %q after stepping down, want -5", got)
+    }
+    m = typ(m, tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyHome}, runes("-"))
+    if got := m.(actionModel).strInputs["p"].Value(); got != "2" {
+        t.Errorf("p = %q, want 2: a field with a Min of 0 steps down on minus", got)
+    }
+}
+
+// TestExplainKey checks that Ctrl+E is left to the text input, which moves
+// to the end of the line, and Ctrl+G toggles the explain panel.
+func TestExplainKey(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    act := registry.Action{
+        ID:         "test/explain",
+        Title:      "Explain",
+        Candidates: []string{"sh"},
+        Template:   map[string]string{"sh": "sh {{q}}"},
+        Fields:     []registry.Field{{Key: "q", Type: "string"}},
+    }
+    var m tea.Model = NewActionModel(act, nil)
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("ab")})
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyHome})
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+    if got := m.(actionModel).strInputs["q"].Value(); got != "abc" {
+        t.Errorf("q = %q, want abc: Ctrl+E should move to the end of the input", got)
+    }
+    if m.(actionModel).explain {
+        t.Error("Ctrl+E toggled the explain panel")
+    }
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+    if !m.(actionModel).explain {
+        t.Error("Ctrl+G did not show the explain panel")
+    }
+}
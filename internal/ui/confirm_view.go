@@ -0,0 +1,124 @@
+package ui
+
+import (
+    "fmt"
+    "os"
+
+    "github.com/BlackOrder/complete-command/internal/config"
+    "github.com/BlackOrder/complete-command/internal/guard"
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// emitMode is what is done with a command once it passes the policy.
+type emitMode int
+
+const (
+    // insertMode inserts the built command into the prompt.
+    insertMode emitMode = iota
+    // runMode runs the built command in the run view.
+    runMode
+    // installMode inserts the command installing the current tool.
+    installMode
+)
+
+// confirmState is a dangerous command waiting for the user's confirmation
+// before it is inserted or run.
+type confirmState struct {
+    command  string
+    level    registry.Level
+    findings []guard.Finding
+    mode     emitMode
+}
+
+// levelStyles colour the danger levels.
+var levelStyles = map[registry.Level]lipgloss.Style{
+    registry.Low:    lipgloss.NewStyle().Foreground(lipgloss.Color("226")),
+    registry.Medium: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("214")),
+    registry.High:   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196")),
+}
+
+// guardCommand applies the configured policy to cmd before it is emitted
+// as mode says. Commands the policy refuses are not emitted and the reason
+// is shown in the form; commands needing confirmation open the
+// confirmation screen. The danger the registry declares for the action does
+// not apply to install commands.
+func (m actionModel) guardCommand(cmd string, mode emitMode) (tea.Model, tea.Cmd) {
+    dir, _ := os.Getwd()
+    tool := m.tools[m.toolIdx]
+    level, findings := guard.Assess(m.action, tool, cmd, dir)
+    if mode == installMode {
+        level = guard.Level(findings)
+    }
+    switch m.cfg.Decide(m.action.ID, tool, level) {
+    case config.Deny:
+        m.refused = fmt.Sprintf("The policy refuses %s commands of %s danger.", m.action.ID, level)
+        return m, nil
+    case config.Confirm:
+        m.confirm = &confirmState{command: cmd, level: level, findings: findings, mode: mode}
+        return m, nil
+    }
+    return m.proceed(cmd, mode)
+}
+
+// proceed emits a command that passed the policy.
+func (m actionModel) proceed(cmd string, mode emitMode) (tea.Model, tea.Cmd) {
+    switch mode {
+    case runMode:
+        return m.execute(cmd)
+    case installMode:
+        m.final = cmd
+        return m, tea.Quit
+    }
+    return m.insert(cmd)
+}
+
+// updateConfirm handles keys on the confirmation screen: y goes ahead,
+// n or Esc returns to where the command was requested.
+func (m actionModel) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    switch msg.String() {
+    case "y", "Y":
+        c := m.confirm
+        m.confirm = nil
+        return m.proceed(c.command, c.mode)
+    case "n", "N", "esc":
+        m.confirm = nil
+    case "ctrl+c":
+        return m, tea.Quit
+    }
+    return m, nil
+}
+
+// viewConfirm renders the confirmation screen. The risky words are marked
+// in the command and listed below it with what makes them risky.
+func (m actionModel) viewConfirm() string {
+    c := m.confirm
+    verb := "insert"
+    if c.mode == runMode {
+        verb = "run"
+    }
+    title := levelStyles[c.level].Render(fmt.Sprintf("Confirm %s-danger command", c.level)) + " • " + m.action.Title
+    instructions := fmt.Sprintf("y to %s • n/ESC to go back", verb)
+    content := title + "\n"
+    content += lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(instructions) + "\n\n"
+    content += highlightMarked(c.command, func(start, end int) bool {
+        for _, f := range c.findings {
+            if f.Start < end && f.End > start {
+                return true
+            }
+        }
+        return false
+    }) + "\n\n"
+    for _, f := range c.findings {
+        word := dangerStyle.Render(c.command[f.Start:f.End])
+        kind := levelStyles[f.Kind.Level()].Render(f.Kind.String())
+        content += fmt.Sprintf("  %s  %s: %s\n", word, kind, f.Reason)
+    }
+    if declared := m.action.DangerLevel(m.tools[m.toolIdx]); c.mode != installMode && declared > registry.None && declared >= guard.Level(c.findings) {
+        content += fmt.Sprintf("  The registry marks %s as %s danger.\n", m.action.ID, declared)
+    }
+    style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("196")).Padding(0, 1)
+    return style.Render(content)
+}
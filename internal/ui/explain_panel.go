@@ -0,0 +1,46 @@
+package ui
+
+import (
+    "strings"
+
+    "github.com/BlackOrder/complete-command/internal/render"
+
+    "github.com/charmbracelet/lipgloss"
+)
+
+// explainFieldStyle renders the field column of the explain panel.
+var explainFieldStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+
+// renderExplain draws the words of the command in a bordered box titled
+// "Explain", one per line with the fields that produced them and their
+// help text. Words are coloured as in the preview.
+func renderExplain(tokens []render.Token) string {
+    title := lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render("Explain (Ctrl+G to hide)")
+    if len(tokens) == 0 {
+        return title + "\n" + previewBoxStyle.Render(previewOpStyle.Render("(nothing to explain yet)"))
+    }
+    textWidth, fieldWidth := 0, 0
+    for _, t := range tokens {
+        textWidth = max(textWidth, lipgloss.Width(t.Text))
+        fieldWidth = max(fieldWidth, lipgloss.Width(strings.Join(t.Fields, ",")))
+    }
+    lines := make([]string, 0, len(tokens))
+    expectCmd := true
+    for _, t := range tokens {
+        text := previewValueStyle
+        switch {
+        case isShellOperator(t.Text):
+            text = previewOpStyle
+            expectCmd = true
+        case expectCmd:
+            text = previewCmdStyle
+            expectCmd = t.Text == "sudo"
+        case strings.HasPrefix(t.Text, "-") || strings.HasPrefix(t.Text, "+"):
+            text = previewFlagStyle
+        }
+        line := text.Width(textWidth).Render(t.Text) + "  " +
+            explainFieldStyle.Width(fieldWidth).Render(strings.Join(t.Fields, ",")) + "  " + t.Help
+        lines = append(lines, strings.TrimRight(line, " "))
+    }
+    return title + "\n" + previewBoxStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,83 @@
+package ui
+
+// This file defines the history browser shown inside the command palette.
+// Each entry is a previously built command; selecting one reopens its action
+// form with the recorded tool and field values so it can be tweaked and
+// rebuilt.
+
+import (
+    "fmt"
+    "io"
+    "time"
+
+    "github.com/BlackOrder/complete-command/internal/history"
+
+    "github.com/charmbracelet/bubbles/list"
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// historyItem wraps a history entry to implement the list.Item interface.
+type historyItem struct {
+    entry history.Entry
+    title string
+}
+
+// FilterValue matches history entries by command text and action title.
+func (h historyItem) FilterValue() string { return h.entry.Command + " " + h.title }
+
+// newHistoryList builds the list of history entries, newest first. titles
+// maps action IDs to their titles for display.
+func newHistoryList(entries []history.Entry, titles map[string]string) list.Model {
+    items := make([]list.Item, 0, len(entries))
+    for _, e := range entries {
+        title := titles[e.Action]
+        if title == "" {
+            title = e.Action
+        }
+        items = append(items, historyItem{entry: e, title: title})
+    }
+    l := list.New(items, historyDelegate{}, 0, 0)
+    l.SetShowStatusBar(false)
+    l.SetShowTitle(false)
+    l.SetFilteringEnabled(true)
+    return l
+}
+
+// historyDelegate renders a history entry as its highlighted command followed
+// by the action title and when it was built.
+type historyDelegate struct{}
+
+func (d historyDelegate) Height() int { return 2 }
+func (d historyDelegate) Spacing() int { return 0 }
+func (d historyDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d historyDelegate) Render(w io.Writer, m list.Model, idx int, listItem list.Item) {
+    item, ok := listItem.(historyItem)
+    if !ok {
+        return
+    }
+    prefix := "  "
+    if idx == m.Index() {
+        prefix = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Render("> ")
+    }
+    meta := fmt.Sprintf("%s • %s • %s", item.title, item.entry.Tool, ago(item.entry.Time, time.Now()))
+    fmt.Fprintf(w, "%s%s\n    %s", prefix, highlightCommand(item.entry.Command),
+        lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(meta))
+}
+
+// ago describes how long before now t was, in the largest sensible unit.
+func ago(t, now time.Time) string {
+    d := now.Sub(t)
+    switch {
+    case d < time.Minute:
+        return "just now"
+    case d < time.Hour:
+        return fmt.Sprintf("%dm ago", int(d.Minutes()))
+    case d < 24*time.Hour:
+        return fmt.Sprintf("%dh ago", int(d.Hours()))
+    case d < 7*24*time.Hour:
+        return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+    }
+    return t.Format("2006-01-02")
+}
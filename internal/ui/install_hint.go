@@ -0,0 +1,31 @@
+package ui
+
+import (
+    "fmt"
+
+    "github.com/BlackOrder/complete-command/internal/render"
+
+    "github.com/charmbracelet/lipgloss"
+)
+
+// installCommand returns the command installing the current tool with the
+// host's package manager, when the registry names its package there.
+func (m actionModel) installCommand() (string, bool) {
+    if m.pkgManager == "" {
+        return "", false
+    }
+    return render.InstallCommand(m.action, m.tools[m.toolIdx], m.pkgManager, m.shell)
+}
+
+// installHint renders the warning shown when none of the action's tools is
+// installed, with the command installing the current one if it is known.
+func (m actionModel) installHint() string {
+    warn := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+    tool := m.tools[m.toolIdx]
+    cmd, ok := m.installCommand()
+    if !ok {
+        return warn.Render(fmt.Sprintf("%s is not installed.", tool))
+    }
+    return warn.Render(fmt.Sprintf("%s is not installed — install with ", tool)) +
+        highlightCommand(cmd) + previewOpStyle.Render("  (Ctrl+P to insert it)")
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+    "strings"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    "github.com/charmbracelet/bubbles/textinput"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// multiField is the list editor behind a field of type multi. The field's
+// text input in actionModel.strInputs is used to type a new entry; ENTER
+// validates it against the field's Entry hint and appends it to the list.
+// While the input is focused, up/down select an entry, shift+up/shift+down
+// move it and ctrl+x removes it; moving past the entries leaves up/down to
+// the form, which moves to the neighbouring field. The template renderer
+// repeats the field's fragment once per entry.
+type multiField struct {
+    field   registry.Field
+    entries []string
+    // sel is the index of the selected entry, or -1 when none is selected.
+    sel int
+    // err holds the last validation error shown under the input.
+    err string
+}
+
+// newMultiField creates the editor for f holding the given entries.
+func newMultiField(f registry.Field, entries []string) *multiField {
+    return &multiField{field: f, entries: append([]string{}, entries...), sel: -1}
+}
+
+// values returns a copy of the current entries.
+func (mf *multiField) values() []string {
+    return append([]string{}, mf.entries...)
+}
+
+// handleKey processes a key press while the field's input is focused. It
+// reports whether the key was consumed; unconsumed keys are passed on to the
+// text input or the form.
+func (mf *multiField) handleKey(key string, ti *textinput.Model) bool {
+    switch key {
+    case "enter":
+        entry := strings.TrimSpace(ti.Value())
+        if entry == "" {
+            // An empty input lets ENTER fall through to building the command.
+            return false
+        }
+        if err := mf.field.ValidateEntry(entry); err != nil {
+            mf.err = err.Error()
+            return true
+        }
+        mf.entries = append(mf.entries, entry)
+        mf.err = ""
+        ti.SetValue("")
+        return true
+    case "up":
+        switch {
+        case len(mf.entries) == 0 || mf.sel == 0:
+            mf.sel = -1
+            return false
+        case mf.sel < 0:
+            mf.sel = len(mf.entries) - 1
+        default:
+            mf.sel--
+        }
+        return true
+    case "down":
+        switch {
+        case mf.sel < 0:
+            return false
+        case mf.sel < len(mf.entries)-1:
+            mf.sel++
+        default:
+            mf.sel = -1
+        }
+        return true
+    case "shift+up", "ctrl+up":
+        mf.move(-1)
+        return true
+    case "shift+down", "ctrl+down":
+        mf.move(1)
+        return true
+    case "ctrl+x":
+        // Without a selected entry ctrl+x is left to the form, which
+        // forgets remembered values.
+        if mf.sel < 0 || mf.sel >= len(mf.entries) {
+            return false
+        }
+        mf.entries = append(mf.entries[:mf.sel], mf.entries[mf.sel+1:]...)
+        if mf.sel >= len(mf.entries) {
+            mf.sel = len(mf.entries) - 1
+        }
+        return true
+    }
+    return false
+}
+
+// move swaps the selected entry with its neighbour in the given direction.
+func (mf *multiField) move(delta int) {
+    j := mf.sel + delta
+    if mf.sel < 0 || j < 0 || j >= len(mf.entries) {
+        return
+    }
+    mf.entries[mf.sel], mf.entries[j] = mf.entries[j], mf.entries[mf.sel]
+    mf.sel = j
+}
+
+// view renders the entries below the field's input, highlighting the
+// selected entry when the input is focused, followed by any error.
+func (mf *multiField) view(focused bool) string {
+    var b strings.Builder
+    dim := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+    if len(mf.entries) == 0 {
+        hint := "no entries"
+        if mf.field.Entry != "" {
+            hint += " • type " + mf.field.Entry + " and press ENTER to add"
+        }
+        b.WriteString("    " + dim.Render(hint) + "\n")
+    }
+    for i, e := range mf.entries {
+        prefix := "  - "
+        style := lipgloss.NewStyle().Foreground(lipgloss.Color("230"))
+        if focused && i == mf.sel {
+            prefix = "  > "
+            style = style.Foreground(lipgloss.Color("205"))
+        }
+        b.WriteString("  " + prefix + style.Render(e) + "\n")
+    }
+    if focused && len(mf.entries) > 0 {
+        b.WriteString("    " + dim.Render("↑/↓ select • shift+↑/↓ move • ctrl+x remove") + "\n")
+    }
+    if mf.err != "" {
+        b.WriteString("    " + lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(mf.err) + "\n")
+    }
+    return b.String()
+}
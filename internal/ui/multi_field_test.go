@@ -0,0 +1,52 @@
+package ui
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/BlackOrder/complete-command/internal/config"
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestMultiCtrlX checks that ctrl+x on a multi row removes the selected
+// entry and, with no entry selected, forgets the remembered values.
+func TestMultiCtrlX(t *testing.T) {
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    act := registry.Action{
+        ID:         "test/multi",
+        Title:      "Multi",
+        Candidates: []string{"sh"},
+        Template:   map[string]string{"sh": `sh {{h | fmt "-e %s"}}`},
+        Fields:     []registry.Field{{Key: "h", Type: "multi", Remember: true}},
+    }
+    cfg, err := config.Load()
+    if err != nil {
+        t.Fatal(err)
+    }
+    cfg.Remember(act.ID, "h", []string{"a", "b"})
+    if err := config.Save(cfg); err != nil {
+        t.Fatal(err)
+    }
+    ctrlX := tea.KeyMsg{Type: tea.KeyCtrlX}
+
+    var m tea.Model = NewActionModel(act, cfg)
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+    m, _ = m.Update(ctrlX)
+    if got := m.(actionModel).multi["h"].values(); !reflect.DeepEqual(got, []string{"a"}) {
+        t.Fatalf("entries after removing the selected one = %q, want [a]", got)
+    }
+    if len(cfg.RememberedValues(act.ID)) == 0 {
+        t.Fatal("removing an entry forgot the remembered values")
+    }
+
+    m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+    m, _ = m.Update(ctrlX)
+    if got := m.(actionModel).notice; got != "Forgot the remembered values." {
+        t.Errorf("notice = %q, want the forget notice", got)
+    }
+    if got := cfg.RememberedValues(act.ID); len(got) != 0 {
+        t.Errorf("remembered values after ctrl+x = %v, want none", got)
+    }
+}
@@ -5,15 +5,23 @@ package ui
 // wraps its view in a rounded border for a more app‑like look.  The palette
 // allows filtering by typing and navigation via the arrow keys.  When an item
 // is selected and Enter is pressed the model exits and exposes the selected
-// action via the PaletteModelAccessor interface.
+// action via the PaletteModelAccessor interface.  Ctrl+R switches to the
+// history of built commands; choosing an entry there exposes both its action
+// and the entry, so the caller can reopen the form pre-filled.  Actions none
+// of whose tools is installed are hidden; Ctrl+A shows them greyed out.
+// Ctrl+P switches between the configuration's profiles.
 
 import (
     "fmt"
     "io"
     "strings"
+    "time"
 
     "github.com/BlackOrder/complete-command/internal/config"
+    "github.com/BlackOrder/complete-command/internal/frecency"
+    "github.com/BlackOrder/complete-command/internal/history"
     "github.com/BlackOrder/complete-command/internal/registry"
+    "github.com/BlackOrder/complete-command/internal/render"
 
     "github.com/charmbracelet/bubbles/list"
     tea "github.com/charmbracelet/bubbletea"
@@ -21,9 +29,15 @@ import (
 )
 
 // paletteItem wraps a registry.Action to implement the list.Item interface.  It
-// exposes the action's title and synonyms for filtering.
+// exposes the action's title and synonyms for filtering.  Items with a header
+// and no action are section titles; recent marks the copies of actions shown
+// in the "Recent" section and unusable those of actions without an installed
+// tool.
 type paletteItem struct {
-    act *registry.Action
+    act      *registry.Action
+    header   string
+    recent   bool
+    unusable bool
 }
 
 // FilterValue returns a string used by the list component to filter items.  It
@@ -42,7 +56,26 @@ func (p paletteItem) FilterValue() string {
 type paletteModel struct {
     list     list.Model
     cfg      *config.Config
+    reg      *registry.Registry
     selected *registry.Action
+
+    // store ranks the actions. usable marks the actions with an installed
+    // tool by ID; showAll also lists the others.
+    store   *frecency.Store
+    usable  map[string]bool
+    showAll bool
+
+    // history lists previously built commands; showHistory switches the
+    // palette to it. entry is the history entry chosen, if any.
+    history     list.Model
+    showHistory bool
+    entry       *history.Entry
+    notice      string
+
+    // profiles lists the configuration's profiles; showProfiles switches
+    // the palette to it.
+    profiles     list.Model
+    showProfiles bool
 }
 
 // GetSelected returns the selected action after the palette model exits.  It is
@@ -51,28 +84,74 @@ func (m paletteModel) GetSelected() *registry.Action {
     return m.selected
 }
 
-// PaletteModelAccessor is an interface exposing the GetSelected method.  The
-// paletteModel implements this interface, allowing the main package to
-// retrieve the chosen action without referencing the concrete type.
+// GetHistoryEntry returns the history entry chosen in the history view, or
+// nil when an action was picked from the palette itself.
+func (m paletteModel) GetHistoryEntry() *history.Entry {
+    return m.entry
+}
+
+// PaletteModelAccessor is an interface exposing the GetSelected and
+// GetHistoryEntry methods.  The paletteModel implements this interface,
+// allowing the main package to retrieve the chosen action without
+// referencing the concrete type.
 type PaletteModelAccessor interface {
     GetSelected() *registry.Action
+    GetHistoryEntry() *history.Entry
 }
 
-// NewPaletteModel constructs a paletteModel with all actions from the
-// registry.  Filtering is enabled to allow searching by synonyms.
+// NewPaletteModel constructs a paletteModel with the usable actions from the
+// registry, detecting the tools of all actions at once.  Filtering is
+// enabled to allow searching by synonyms, and both the unfiltered list and
+// filter results are ranked by frecency.
 func NewPaletteModel(reg *registry.Registry, cfg *config.Config) paletteModel {
-    var items []list.Item
-    for i := range reg.Actions {
-        act := &reg.Actions[i]
-        items = append(items, paletteItem{act: act})
+    titles := make(map[string]string)
+    for _, act := range reg.Actions {
+        titles[act.ID] = act.Title
     }
-    l := list.New(items, paletteDelegate{}, 0, 0)
+    // Without readable usage state the palette keeps registry order.
+    store, _ := frecency.Load()
+    l := list.New(nil, paletteDelegate{}, 0, 0)
     l.SetShowStatusBar(false)
+    l.SetShowTitle(false)
     l.SetFilteringEnabled(true)
-    return paletteModel{
-        list: l,
-        cfg:  cfg,
+    // A missing or unreadable history just leaves the history view empty.
+    entries, _ := history.Load()
+    m := paletteModel{
+        list:     l,
+        cfg:      cfg,
+        reg:      reg,
+        store:    store,
+        usable:   render.Detect(reg),
+        history:  newHistoryList(entries, titles),
+        profiles: newProfileList(cfg),
+    }
+    m.fillList()
+    return m
+}
+
+// fillList sets the palette entries, leaving out the unusable actions unless
+// showAll is set.
+func (m *paletteModel) fillList() {
+    now := time.Now()
+    items := paletteItems(m.reg, m.store, now, m.usable, m.showAll)
+    m.list.SetItems(items)
+    m.list.Filter = frecencyFilter(items, m.store, now)
+    m.list.Select(0)
+    skipHeader(&m.list, -1)
+}
+
+// hidden returns the number of actions left out of the palette.
+func (m paletteModel) hidden() int {
+    if m.showAll {
+        return 0
+    }
+    n := 0
+    for _, act := range m.reg.Actions {
+        if !m.usable[act.ID] {
+            n++
+        }
     }
+    return n
 }
 
 // Init returns nil; no asynchronous initialization is required.
@@ -82,20 +161,135 @@ func (m paletteModel) Init() tea.Cmd { return nil }
 // is pressed, the selected action is stored and the program quits.
 func (m paletteModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     switch msg := msg.(type) {
+    case tea.WindowSizeMsg:
+        // Leave room for the border, padding and header.
+        m.list.SetSize(msg.Width-4, msg.Height-7)
+        m.history.SetSize(msg.Width-4, msg.Height-7)
+        m.profiles.SetSize(msg.Width-4, msg.Height-7)
+        return m, nil
     case tea.KeyMsg:
+        m.notice = ""
+        if m.showHistory {
+            return m.updateHistory(msg)
+        }
+        if m.showProfiles {
+            return m.updateProfiles(msg)
+        }
         switch msg.String() {
         case "ctrl+c", "esc":
             return m, tea.Quit
+        case "ctrl+r":
+            m.showHistory = true
+            return m, nil
+        case "ctrl+p":
+            m.showProfiles = true
+            return m, nil
+        case "ctrl+a":
+            m.showAll = !m.showAll
+            m.fillList()
+            return m, nil
         case "enter":
             // On enter, record selected action and quit.
-            if item, ok := m.list.SelectedItem().(paletteItem); ok {
+            item, ok := m.list.SelectedItem().(paletteItem)
+            if ok && item.act == nil {
+                return m, nil
+            }
+            if ok {
                 m.selected = item.act
             }
             return m, tea.Quit
         }
     }
+    prev := m.list.Index()
     var cmd tea.Cmd
     m.list, cmd = m.list.Update(msg)
+    skipHeader(&m.list, prev)
+    return m, cmd
+}
+
+// skipHeader moves the selection off a section header, continuing in the
+// direction it moved from prev.
+func skipHeader(l *list.Model, prev int) {
+    item, ok := l.SelectedItem().(paletteItem)
+    if !ok || item.act != nil {
+        return
+    }
+    if l.Index() < prev && l.Index() > 0 {
+        l.CursorUp()
+    } else {
+        l.CursorDown()
+    }
+}
+
+// updateHistory handles keys while the history view is shown.  Enter reopens
+// the chosen entry's action; Esc or Ctrl+R returns to the action list.
+func (m paletteModel) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    switch msg.String() {
+    case "ctrl+c":
+        return m, tea.Quit
+    case "esc", "ctrl+r":
+        if m.history.FilterState() == list.Filtering {
+            break
+        }
+        m.showHistory = false
+        return m, nil
+    case "enter":
+        if m.history.FilterState() == list.Filtering {
+            break
+        }
+        item, ok := m.history.SelectedItem().(historyItem)
+        if !ok {
+            return m, nil
+        }
+        act := m.reg.Find(item.entry.Action)
+        if act == nil || act.ID != item.entry.Action {
+            m.notice = fmt.Sprintf("Action %s is no longer in the registry.", item.entry.Action)
+            return m, nil
+        }
+        entry := item.entry
+        m.selected, m.entry = act, &entry
+        return m, tea.Quit
+    }
+    var cmd tea.Cmd
+    m.history, cmd = m.history.Update(msg)
+    return m, cmd
+}
+
+// updateProfiles handles keys while the profile switcher is shown.  Enter
+// makes the chosen profile active and saves it as the default; Esc or
+// Ctrl+P returns to the action list.
+func (m paletteModel) updateProfiles(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    switch msg.String() {
+    case "ctrl+c":
+        return m, tea.Quit
+    case "esc", "ctrl+p":
+        if m.profiles.FilterState() == list.Filtering {
+            break
+        }
+        m.showProfiles = false
+        return m, nil
+    case "enter":
+        if m.profiles.FilterState() == list.Filtering || m.cfg == nil {
+            break
+        }
+        item, ok := m.profiles.SelectedItem().(profileItem)
+        if !ok {
+            return m, nil
+        }
+        if err := m.cfg.Use(item.name); err != nil {
+            m.notice = err.Error()
+            return m, nil
+        }
+        if err := config.Update(m.cfg, func(c *config.Config) { c.Profile = item.name }); err != nil {
+            m.notice = "Profile not saved: " + err.Error()
+        }
+        m.profiles = newProfileList(m.cfg)
+        m.profiles.SetSize(m.list.Width(), m.list.Height())
+        m.showProfiles = false
+        return m, nil
+    }
+    var cmd tea.Cmd
+    m.profiles, cmd = m.profiles.Update(msg)
     return m, cmd
 }
 
@@ -104,9 +298,36 @@ func (m paletteModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // an app‑like feel.
 func (m paletteModel) View() string {
     // Colourful header and instructions using lipgloss.
-    title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")).Render("Command palette")
-    instr := lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render("Use ↑/↓ or type to filter • Enter to select • ESC to quit")
-    content := fmt.Sprintf("%s\n%s\n\n%s", title, instr, m.list.View())
+    titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+    instrStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+    var content string
+    if m.showProfiles {
+        instr := "Use ↑/↓ or / to filter • Enter to switch • ESC or Ctrl+P to go back"
+        content = fmt.Sprintf("%s\n%s\n\n%s", titleStyle.Render("Profiles"), instrStyle.Render(instr), m.profiles.View())
+    } else if m.showHistory {
+        instr := "Use ↑/↓ or / to filter • Enter to reopen • ESC or Ctrl+R to go back"
+        body := m.history.View()
+        if len(m.history.Items()) == 0 {
+            body = instrStyle.Render("No commands built yet.")
+        }
+        content = fmt.Sprintf("%s\n%s\n\n%s", titleStyle.Render("History"), instrStyle.Render(instr), body)
+    } else {
+        instr := "Use ↑/↓ or type to filter • Enter to select • Ctrl+R history • Ctrl+A all actions • Ctrl+P profiles • ESC to quit"
+        if m.showAll {
+            instr = "Use ↑/↓ or type to filter • Enter to select • Ctrl+R history • Ctrl+A usable actions • Ctrl+P profiles • ESC to quit"
+        }
+        title := titleStyle.Render("Command palette")
+        if m.cfg != nil && m.cfg.Active() != config.Base {
+            title += instrStyle.Render(" • profile " + m.cfg.Active())
+        }
+        content = fmt.Sprintf("%s\n%s\n\n%s", title, instrStyle.Render(instr), m.list.View())
+        if n := m.hidden(); n > 0 {
+            content += "\n" + instrStyle.Render(fmt.Sprintf("%d actions hidden: no tool installed (Ctrl+A to show)", n))
+        }
+    }
+    if m.notice != "" {
+        content += "\n" + errorStyle.Render(m.notice)
+    }
     // Wrap in a rounded border with padding.
     style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1)
     return style.Render(content)
@@ -132,13 +353,20 @@ func (d paletteDelegate) Render(w io.Writer, m list.Model, idx int, listItem lis
     } else {
         prefix = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("  ")
     }
-    if item, ok := listItem.(paletteItem); ok && item.act != nil {
+    if item, ok := listItem.(paletteItem); ok && item.act == nil && item.header != "" {
+        // Section headers are never selected.
+        fmt.Fprint(w, lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("244")).Render(item.header))
+    } else if ok && item.act != nil && item.unusable {
+        // Actions without an installed tool are greyed out.
+        grey := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+        fmt.Fprintf(w, "%s%s", prefix, grey.Render(fmt.Sprintf("%s (%s) – not installed", item.act.Title, strings.Join(item.act.Candidates, "/"))))
+    } else if ok && item.act != nil {
         title := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(item.act.Title)
         cands := lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(strings.Join(item.act.Candidates, "/"))
-        fmt.Fprintf(w, "%s%s (%s)\n", prefix, title, cands)
+        fmt.Fprintf(w, "%s%s (%s)", prefix, title, cands)
     } else {
         // Fallback rendering for unexpected types.
         itemStr := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(fmt.Sprint(listItem))
-        fmt.Fprintf(w, "%s%s\n", prefix, itemStr)
+        fmt.Fprintf(w, "%s%s", prefix, itemStr)
     }
 }
\ No newline at end of file
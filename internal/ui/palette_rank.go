@@ -0,0 +1,89 @@
+package ui
+
+// This file ranks the command palette. With an empty filter the palette shows
+// the most frecent actions in a "Recent" section above the full list; while
+// filtering, fuzzy match scores are blended with frecency so that actions used
+// often and lately rise among similarly good matches.
+
+import (
+    "sort"
+    "time"
+
+    "github.com/BlackOrder/complete-command/internal/frecency"
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    "github.com/charmbracelet/bubbles/list"
+    "github.com/sahilm/fuzzy"
+)
+
+// recentCount is the number of actions shown in the "Recent" section.
+const recentCount = 5
+
+// paletteItems builds the palette entries: a "Recent" section with the most
+// frecent actions followed by every action in registry order. Without any
+// usage recorded the sections and their headers are left out. Actions that
+// usable, when not nil, marks as having no installed candidate are left out
+// too, or kept and marked unusable with showAll.
+func paletteItems(reg *registry.Registry, store *frecency.Store, now time.Time, usable map[string]bool, showAll bool) []list.Item {
+    item := func(act *registry.Action, recent bool) (paletteItem, bool) {
+        unusable := usable != nil && !usable[act.ID]
+        return paletteItem{act: act, recent: recent, unusable: unusable}, showAll || !unusable
+    }
+    var recent []list.Item
+    for _, id := range store.Top(recentCount, now) {
+        for i := range reg.Actions {
+            if reg.Actions[i].ID == id {
+                if it, ok := item(&reg.Actions[i], true); ok {
+                    recent = append(recent, it)
+                }
+                break
+            }
+        }
+    }
+    var items []list.Item
+    if len(recent) > 0 {
+        items = append(items, paletteItem{header: "Recent"})
+        items = append(items, recent...)
+        items = append(items, paletteItem{header: "All actions"})
+    }
+    for i := range reg.Actions {
+        if it, ok := item(&reg.Actions[i], false); ok {
+            items = append(items, it)
+        }
+    }
+    return items
+}
+
+// frecencyFilter returns a list filter that fuzzy matches the palette items
+// and orders the matches by match score blended with frecency. Headers and
+// the duplicate entries of the "Recent" section are never matched.
+func frecencyFilter(items []list.Item, store *frecency.Store, now time.Time) list.FilterFunc {
+    return func(term string, targets []string) []list.Rank {
+        var idx []int
+        var cands []string
+        for i, it := range items {
+            if pi, ok := it.(paletteItem); ok && pi.act != nil && !pi.recent && i < len(targets) {
+                idx = append(idx, i)
+                cands = append(cands, targets[i])
+            }
+        }
+        matches := fuzzy.Find(term, cands)
+        match := make([]float64, len(matches))
+        frec := make([]float64, len(matches))
+        for i, mt := range matches {
+            match[i] = float64(mt.Score)
+            frec[i] = store.Score(items[idx[mt.Index]].(paletteItem).act.ID, now)
+        }
+        scores := frecency.Blend(match, frec)
+        order := make([]int, len(matches))
+        for i := range order {
+            order[i] = i
+        }
+        sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+        ranks := make([]list.Rank, len(matches))
+        for i, o := range order {
+            ranks[i] = list.Rank{Index: idx[matches[o].Index], MatchedIndexes: matches[o].MatchedIndexes}
+        }
+        return ranks
+    }
+}
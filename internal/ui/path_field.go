@@ -0,0 +1,290 @@
+package ui
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
+
+    "github.com/charmbracelet/bubbles/textinput"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// browserRows is the number of entries the directory browser shows at once.
+const browserRows = 10
+
+// pathField is the editor behind a field of type path, typed in its text
+// input in actionModel.strInputs. TAB completes the input against the
+// filesystem, listing the candidates when they share no longer prefix, and
+// moves to the next field when there is nothing left to complete. Ctrl+O
+// opens a directory browser below the input. A leading "~" stands for the
+// home directory, and glob patterns show how many files they match in
+// fields the shell expands them for. Fields whose must rule is dir only
+// offer directories.
+type pathField struct {
+    field registry.Field
+    // matches lists the candidates shown after an ambiguous TAB.
+    matches []string
+
+    // browsing is set while the browser is open on dir; entries lists the
+    // directory's subdirectories, with a trailing slash, and files, and
+    // sel is the index of the selected entry.
+    browsing bool
+    dir      string
+    entries  []string
+    sel      int
+    err      string
+}
+
+// newPathField creates the editor for f.
+func newPathField(f registry.Field) *pathField {
+    return &pathField{field: f}
+}
+
+// dirsOnly reports whether only directories are offered.
+func (pf *pathField) dirsOnly() bool {
+    return pf.field.Must == "dir"
+}
+
+// handleKey processes a key press while the field's input is focused. It
+// reports whether the key was consumed; unconsumed keys are passed on to the
+// text input or the form.
+func (pf *pathField) handleKey(key string, ti *textinput.Model) bool {
+    if pf.browsing {
+        return pf.browse(key, ti)
+    }
+    switch key {
+    case "tab":
+        return pf.complete(ti)
+    case "ctrl+o":
+        pf.open(ti.Value())
+        return true
+    }
+    pf.matches = nil
+    return false
+}
+
+// complete extends the input to the longest prefix shared by the entries it
+// matches. When that adds nothing, the candidates are listed once; after
+// that TAB is left to the form.
+func (pf *pathField) complete(ti *textinput.Model) bool {
+    value := ti.Value()
+    if value == "~" {
+        ti.SetValue("~/")
+        ti.CursorEnd()
+        return true
+    }
+    dir, base := filepath.Split(value)
+    matches := pf.list(dir, strings.HasPrefix(base, "."))
+    var found []string
+    for _, m := range matches {
+        if strings.HasPrefix(m, base) {
+            found = append(found, m)
+        }
+    }
+    if len(found) == 0 {
+        pf.matches = nil
+        return false
+    }
+    if prefix := commonPrefix(found); len(prefix) > len(base) {
+        ti.SetValue(dir + prefix)
+        ti.CursorEnd()
+        pf.matches = nil
+        if len(found) > 1 {
+            pf.matches = found
+        }
+        return true
+    }
+    if len(found) > 1 && pf.matches == nil {
+        pf.matches = found
+        return true
+    }
+    pf.matches = nil
+    return false
+}
+
+// list returns the names in dir, as typed, that the field offers:
+// directories with a trailing slash first, then files unless only
+// directories are offered. Hidden entries are left out unless hidden is set.
+func (pf *pathField) list(dir string, hidden bool) []string {
+    read := registry.ExpandHome(dir)
+    if read == "" {
+        read = "."
+    }
+    ents, err := os.ReadDir(read)
+    if err != nil {
+        return nil
+    }
+    var dirs, files []string
+    for _, e := range ents {
+        name := e.Name()
+        if strings.HasPrefix(name, ".") && !hidden {
+            continue
+        }
+        if isDir(filepath.Join(read, name), e) {
+            dirs = append(dirs, name+"/")
+        } else if !pf.dirsOnly() {
+            files = append(files, name)
+        }
+    }
+    sort.Strings(dirs)
+    sort.Strings(files)
+    return append(dirs, files...)
+}
+
+// isDir reports whether the entry at path is a directory or a link to one.
+func isDir(path string, e os.DirEntry) bool {
+    if e.Type()&os.ModeSymlink != 0 {
+        st, err := os.Stat(path)
+        return err == nil && st.IsDir()
+    }
+    return e.IsDir()
+}
+
+// commonPrefix returns the longest prefix shared by all of ss.
+func commonPrefix(ss []string) string {
+    prefix := ss[0]
+    for _, s := range ss[1:] {
+        for !strings.HasPrefix(s, prefix) {
+            prefix = prefix[:len(prefix)-1]
+        }
+    }
+    // Do not split a multi-byte character.
+    for !utf8.ValidString(prefix) {
+        prefix = prefix[:len(prefix)-1]
+    }
+    return prefix
+}
+
+// open opens the browser on the directory value names, or the one holding
+// it, falling back to the working directory.
+func (pf *pathField) open(value string) {
+    dir := registry.ExpandHome(value)
+    if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+        dir = filepath.Dir(dir)
+    }
+    if st, err := os.Stat(dir); err != nil || !st.IsDir() || value == "" {
+        dir = "."
+    }
+    pf.browsing, pf.matches = true, nil
+    pf.load(dir)
+}
+
+// load shows dir in the browser. The first entry picks dir itself and the
+// second goes to its parent.
+func (pf *pathField) load(dir string) {
+    pf.dir, pf.sel, pf.err = filepath.Clean(dir), 0, ""
+    pf.entries = []string{"./", "../"}
+    if _, err := os.ReadDir(pf.dir); err != nil {
+        pf.err = err.Error()
+        return
+    }
+    pf.entries = append(pf.entries, pf.list(pf.dir+"/", false)...)
+}
+
+// browse handles keys while the browser is open. ENTER or → opens the
+// selected directory and ENTER picks a file or, on "./", the directory
+// shown; ← or backspace goes to the parent and ESC or Ctrl+O closes the
+// browser. Other keys are ignored.
+func (pf *pathField) browse(key string, ti *textinput.Model) bool {
+    switch key {
+    case "ctrl+c":
+        return false
+    case "esc", "ctrl+o":
+        pf.browsing = false
+    case "up":
+        if pf.sel > 0 {
+            pf.sel--
+        }
+    case "down":
+        if pf.sel < len(pf.entries)-1 {
+            pf.sel++
+        }
+    case "left", "backspace":
+        pf.load(filepath.Join(pf.dir, ".."))
+    case "right", "enter":
+        name := pf.entries[pf.sel]
+        switch {
+        case name == "./" && key == "enter":
+            pf.pick(ti, pf.dir)
+        case name == "./":
+        case strings.HasSuffix(name, "/"):
+            pf.load(filepath.Join(pf.dir, name))
+        case key == "enter":
+            pf.pick(ti, filepath.Join(pf.dir, name))
+        }
+    }
+    return true
+}
+
+// pick sets the input to path, written with "~" when it is in the home
+// directory, and closes the browser.
+func (pf *pathField) pick(ti *textinput.Model, path string) {
+    if home, err := os.UserHomeDir(); err == nil && filepath.IsAbs(path) {
+        if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+            path = filepath.Join("~", rel)
+        }
+    }
+    ti.SetValue(path)
+    ti.CursorEnd()
+    pf.browsing = false
+}
+
+// view renders the candidates, the browser or the glob preview below the
+// field's input.
+func (pf *pathField) view(value string, focused bool) string {
+    dim := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+    var b strings.Builder
+    switch {
+    case focused && pf.browsing:
+        b.WriteString("    " + lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Render(pf.dir) + "\n")
+        if pf.err != "" {
+            b.WriteString("    " + errorStyle.Render(pf.err) + "\n")
+        }
+        start := 0
+        if pf.sel >= browserRows {
+            start = pf.sel - browserRows + 1
+        }
+        for i := start; i < len(pf.entries) && i < start+browserRows; i++ {
+            prefix := "  - "
+            style := lipgloss.NewStyle().Foreground(lipgloss.Color("230"))
+            if i == pf.sel {
+                prefix = "  > "
+                style = style.Foreground(lipgloss.Color("205"))
+            }
+            b.WriteString("  " + prefix + style.Render(pf.entries[i]) + "\n")
+        }
+        b.WriteString("    " + dim.Render("↑/↓ select • →/ENTER open • ENTER on ./ picks it • ←/backspace parent • ESC close") + "\n")
+    case focused && len(pf.matches) > 0:
+        b.WriteString("    " + dim.Render(strings.Join(pf.matches, "  ")) + "\n")
+    case focused:
+        b.WriteString("    " + dim.Render("TAB complete • Ctrl+O browse") + "\n")
+    }
+    if registry.IsGlob(value) {
+        pf.preview(&b, value)
+    }
+    return b.String()
+}
+
+// preview writes how many files the pattern value matches or, when the
+// field does not let the shell expand patterns, that it is taken literally.
+func (pf *pathField) preview(b *strings.Builder, value string) {
+    dim := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+    if !pf.field.Glob {
+        b.WriteString("    " + dim.Render("not a pattern here: the name is used as typed") + "\n")
+        return
+    }
+    matches, err := filepath.Glob(registry.ExpandHome(value))
+    switch {
+    case err != nil:
+        b.WriteString("    " + errorStyle.Render("invalid pattern") + "\n")
+    case len(matches) == 1:
+        b.WriteString("    " + dim.Render("1 file matches") + "\n")
+    default:
+        b.WriteString("    " + dim.Render(fmt.Sprintf("%d files match", len(matches))) + "\n")
+    }
+}
@@ -0,0 +1,30 @@
+package ui
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/BlackOrder/complete-command/internal/registry"
+)
+
+// TestPathGlobPreview checks that a pattern shows how many files it matches
+// in a glob field, and that other path fields say it is taken literally.
+func TestPathGlobPreview(t *testing.T) {
+    dir := t.TempDir()
+    for _, name := range []string{"a.log", "b.log", "c.txt"} {
+        if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    pattern := filepath.Join(dir, "*.log")
+    glob := newPathField(registry.Field{Key: "p", Type: "path", Glob: true})
+    if got := glob.view(pattern, false); !strings.Contains(got, "2 files match") {
+        t.Errorf("glob field preview = %q, want 2 files match", got)
+    }
+    literal := newPathField(registry.Field{Key: "p", Type: "path"})
+    if got := literal.view(pattern, false); !strings.Contains(got, "used as typed") {
+        t.Errorf("literal field preview = %q, want a note that the name is used as typed", got)
+    }
+}
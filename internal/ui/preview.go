@@ -0,0 +1,77 @@
+package ui
+
+import (
+    "strings"
+
+    "github.com/BlackOrder/complete-command/internal/shellquote"
+
+    "github.com/charmbracelet/lipgloss"
+)
+
+// Styles used by the command preview. The command name is bold, flags are
+// cyan, values are yellow and shell operators are grey so the structure of
+// the rendered command is visible at a glance.
+var (
+    previewCmdStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+    previewFlagStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("81"))
+    previewValueStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("222"))
+    previewOpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+    previewBoxStyle   = lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color("240")).Padding(0, 1)
+    dangerStyle       = lipgloss.NewStyle().Bold(true).Underline(true).Foreground(lipgloss.Color("196"))
+)
+
+// renderPreview draws the command in a bordered box titled "Preview". An
+// empty command is shown as a placeholder.
+func renderPreview(cmd string) string {
+    title := lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render("Preview")
+    body := highlightCommand(cmd)
+    if cmd == "" {
+        body = previewOpStyle.Render("(nothing to insert yet)")
+    }
+    return title + "\n" + previewBoxStyle.Render(body)
+}
+
+// highlightCommand colours each shell word of cmd. The first word of every
+// pipeline stage is treated as the command name, words starting with '-' or
+// '+' as flags and everything else as values.
+func highlightCommand(cmd string) string {
+    return highlightMarked(cmd, nil)
+}
+
+// highlightMarked is like highlightCommand but shows the words for which
+// marked reports true, given their offsets in cmd, in the danger style.
+func highlightMarked(cmd string, marked func(start, end int) bool) string {
+    var b strings.Builder
+    expectCmd := true
+    for i, sw := range shellquote.Words(cmd) {
+        if i > 0 {
+            b.WriteByte(' ')
+        }
+        w := cmd[sw.Start:sw.End]
+        switch {
+        case marked != nil && marked(sw.Start, sw.End):
+            b.WriteString(dangerStyle.Render(w))
+            expectCmd = expectCmd && (w == "sudo" || isShellOperator(w))
+        case isShellOperator(w):
+            b.WriteString(previewOpStyle.Render(w))
+            expectCmd = true
+        case expectCmd:
+            b.WriteString(previewCmdStyle.Render(w))
+            expectCmd = w == "sudo"
+        case strings.HasPrefix(w, "-") || strings.HasPrefix(w, "+"):
+            b.WriteString(previewFlagStyle.Render(w))
+        default:
+            b.WriteString(previewValueStyle.Render(w))
+        }
+    }
+    return b.String()
+}
+
+// isShellOperator reports whether w separates commands.
+func isShellOperator(w string) bool {
+    switch w {
+    case "|", "||", "&&", ";", "&":
+        return true
+    }
+    return false
+}
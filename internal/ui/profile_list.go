@@ -0,0 +1,90 @@
+package ui
+
+// This file defines the profile switcher shown inside the command palette.
+// It lists the base and the named profiles of the configuration; choosing
+// one makes it active for the session and the default for later ones.
+
+import (
+    "fmt"
+    "io"
+
+    "github.com/BlackOrder/complete-command/internal/config"
+
+    "github.com/charmbracelet/bubbles/list"
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// profileItem wraps a profile name to implement the list.Item interface.
+// inherits names the profile it inherits from and active marks the one in
+// use.
+type profileItem struct {
+    name     string
+    inherits string
+    active   bool
+}
+
+// FilterValue matches profiles by name.
+func (p profileItem) FilterValue() string { return p.name }
+
+// newProfileList builds the list of profiles of cfg with the active one
+// selected.
+func newProfileList(cfg *config.Config) list.Model {
+    var items []list.Item
+    selected := 0
+    if cfg != nil {
+        for i, name := range cfg.ProfileNames() {
+            item := profileItem{name: name, active: name == cfg.Active()}
+            if p := cfg.Profiles[name]; p != nil && name != config.Base {
+                item.inherits = p.Inherits
+                if item.inherits == "" {
+                    item.inherits = config.Base
+                }
+            }
+            if item.active {
+                selected = i
+            }
+            items = append(items, item)
+        }
+    }
+    l := list.New(items, profileDelegate{}, 0, 0)
+    l.SetShowStatusBar(false)
+    l.SetShowTitle(false)
+    l.SetFilteringEnabled(true)
+    l.Select(selected)
+    return l
+}
+
+// profileDelegate renders a profile as its name followed by the profile it
+// inherits from, marking the active one.
+type profileDelegate struct{}
+
+func (d profileDelegate) Height() int { return 1 }
+func (d profileDelegate) Spacing() int { return 0 }
+func (d profileDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d profileDelegate) Render(w io.Writer, m list.Model, idx int, listItem list.Item) {
+    item, ok := listItem.(profileItem)
+    if !ok {
+        return
+    }
+    prefix := "  "
+    if idx == m.Index() {
+        prefix = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Render("> ")
+    }
+    name := lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Render(item.name)
+    var meta string
+    if item.inherits != "" {
+        meta = "inherits " + item.inherits
+    }
+    if item.active {
+        if meta != "" {
+            meta += " • "
+        }
+        meta += "active"
+    }
+    if meta != "" {
+        meta = lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(" (" + meta + ")")
+    }
+    fmt.Fprintf(w, "%s%s%s", prefix, name, meta)
+}
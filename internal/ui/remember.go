@@ -0,0 +1,48 @@
+package ui
+
+import (
+    "github.com/BlackOrder/complete-command/internal/config"
+    "github.com/BlackOrder/complete-command/internal/render"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// remembers reports whether the action has fields whose values are
+// remembered.
+func (m actionModel) remembers() bool {
+    for _, f := range m.action.Fields {
+        if f.Remember {
+            return true
+        }
+    }
+    return false
+}
+
+// remember stores the values of the fields marked remember and, with pref
+// set, the current tool as the preferred one.
+func (m actionModel) remember(pref bool) {
+    if m.cfg == nil {
+        return
+    }
+    tool, values := m.tools[m.toolIdx], m.shownValues()
+    _ = config.Update(m.cfg, func(c *config.Config) {
+        if pref && m.prefKey != "" {
+            c.SetPreference(m.prefKey, tool)
+        }
+        render.Remember(m.action, c, values)
+    })
+}
+
+// forget drops the remembered values of the action and resets the form to
+// the registry defaults, or to the values the active profile inherits,
+// keeping the current tool and the last run.
+func (m actionModel) forget() (tea.Model, tea.Cmd) {
+    if m.cfg == nil || !m.remembers() {
+        return m, nil
+    }
+    _ = config.Update(m.cfg, func(c *config.Config) { c.Forget(m.action.ID) })
+    fresh := NewPrefilledActionModel(m.action, m.cfg, m.tools[m.toolIdx], render.Remembered(m.action, m.cfg))
+    fresh.width, fresh.height, fresh.run = m.width, m.height, m.run
+    fresh.notice = "Forgot the remembered values."
+    return fresh, nil
+}
@@ -0,0 +1,191 @@
+package ui
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/BlackOrder/complete-command/internal/runner"
+
+    "github.com/charmbracelet/bubbles/viewport"
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// runState is a command started from the action form with its output so
+// far and, once it has finished, its outcome.
+type runState struct {
+    r       *runner.Run
+    command string
+    out     runner.Output
+    view    viewport.Model
+    // err is set when the command could not be started.
+    err      error
+    done     bool
+    exit     int
+    duration time.Duration
+}
+
+// runEventMsg delivers an event of run r to the model.
+type runEventMsg struct {
+    r  *runner.Run
+    ev runner.Event
+}
+
+// waitRun returns a command waiting for the next event of r.
+func waitRun(r *runner.Run) tea.Cmd {
+    return func() tea.Msg {
+        ev, ok := <-r.Events()
+        if !ok {
+            return nil
+        }
+        return runEventMsg{r: r, ev: ev}
+    }
+}
+
+// drain discards the remaining events of a run that is no longer shown, so
+// its reader can finish.
+func drain(r *runner.Run) {
+    go func() {
+        for range r.Events() {
+        }
+    }()
+}
+
+// runSize returns the size of the output viewport for the window size.
+func (m actionModel) runSize() (int, int) {
+    w, h := 80, 20
+    if m.width > 0 {
+        // Leave room for the border, padding, header and status line.
+        w, h = m.width-4, m.height-10
+    }
+    return max(w, 20), max(h, 3)
+}
+
+// startRun validates the form and, when the policy lets the command
+// through, runs it.
+func (m actionModel) startRun() (tea.Model, tea.Cmd) {
+    m.attempted = true
+    if !m.validate() {
+        return m, nil
+    }
+    cmd, err := m.buildCommand()
+    if err != nil {
+        return m, nil
+    }
+    return m.guardCommand(cmd, runMode)
+}
+
+// execute runs cmd in a pseudo-terminal, replacing any previous run, and
+// switches to its output.
+func (m actionModel) execute(cmd string) (tea.Model, tea.Cmd) {
+    m.remember(false)
+    if old := m.run; old != nil && old.r != nil && !old.done {
+        _ = old.r.Kill()
+        drain(old.r)
+    }
+    w, h := m.runSize()
+    r, err := runner.Start(runner.Shell(), cmd, w, h)
+    m.run = &runState{r: r, command: cmd, view: viewport.New(w, h), err: err}
+    m.showRun = true
+    if err != nil {
+        return m, nil
+    }
+    return m, waitRun(r)
+}
+
+// updateRunEvent records output or the outcome of the current run.
+func (m actionModel) updateRunEvent(msg runEventMsg) (tea.Model, tea.Cmd) {
+    if m.run == nil || m.run.r != msg.r {
+        return m, nil
+    }
+    rs := m.run
+    if len(msg.ev.Output) > 0 {
+        follow := rs.view.AtBottom()
+        rs.out.Write(msg.ev.Output)
+        rs.view.SetContent(rs.out.String())
+        if follow {
+            rs.view.GotoBottom()
+        }
+    }
+    if msg.ev.Done {
+        rs.done, rs.exit, rs.duration = true, msg.ev.Exit, msg.ev.Duration
+        if msg.ev.Err != nil {
+            rs.err = msg.ev.Err
+        }
+        return m, nil
+    }
+    return m, waitRun(msg.r)
+}
+
+// updateRun handles keys while the output of a run is shown. Ctrl+R reruns
+// the command, Enter inserts it, Esc returns to the form, stopping the
+// command if it is still running, and Ctrl+C interrupts it. Other keys
+// scroll the output.
+func (m actionModel) updateRun(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+    rs := m.run
+    running := rs.r != nil && !rs.done
+    m.refused = ""
+    switch msg.String() {
+    case "ctrl+c":
+        if running {
+            _ = rs.r.Interrupt()
+            return m, nil
+        }
+        return m, tea.Quit
+    case "esc":
+        if running {
+            _ = rs.r.Kill()
+        }
+        m.showRun = false
+        return m, nil
+    case "ctrl+r":
+        return m.startRun()
+    case "enter":
+        return m.build()
+    }
+    var cmd tea.Cmd
+    rs.view, cmd = rs.view.Update(msg)
+    return m, cmd
+}
+
+// resizeRun fits the output viewport and the command's terminal to the
+// window.
+func (m actionModel) resizeRun() {
+    if m.run == nil {
+        return
+    }
+    w, h := m.runSize()
+    m.run.view.Width, m.run.view.Height = w, h
+    if m.run.r != nil && !m.run.done {
+        _ = m.run.r.Resize(w, h)
+    }
+}
+
+// status describes the state of the run in one line.
+func (rs *runState) status() string {
+    switch {
+    case rs.err != nil:
+        return errorStyle.Render(fmt.Sprintf("Could not run: %v", rs.err))
+    case !rs.done:
+        return previewFlagStyle.Render(fmt.Sprintf("Running… %s", time.Since(rs.r.Started).Round(time.Second)))
+    case rs.exit == 0:
+        return lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render(fmt.Sprintf("Exit status 0 • %s", rs.duration.Round(time.Millisecond)))
+    }
+    return errorStyle.Render(fmt.Sprintf("Exit status %d • %s", rs.exit, rs.duration.Round(time.Millisecond)))
+}
+
+// viewRun renders the output of the current run.
+func (m actionModel) viewRun() string {
+    rs := m.run
+    title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")).Render(m.action.Title)
+    instructions := "↑/↓ to scroll • Ctrl+R to rerun • ENTER to insert • ESC back to form • Ctrl+C to interrupt"
+    content := title + " • Output\n"
+    content += lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(instructions) + "\n\n"
+    content += previewOpStyle.Render("$ ") + highlightCommand(rs.command) + "\n\n"
+    content += rs.view.View() + "\n\n" + rs.status()
+    if m.refused != "" {
+        content += "\n" + errorStyle.Render(m.refused)
+    }
+    style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+    return style.Render(content)
+}
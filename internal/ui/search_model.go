@@ -13,6 +13,7 @@ import (
 
     "github.com/BlackOrder/complete-command/internal/actions"
     "github.com/BlackOrder/complete-command/internal/config"
+    "github.com/BlackOrder/complete-command/internal/shellquote"
 
     "github.com/charmbracelet/bubbles/list"
     "github.com/charmbracelet/bubbles/textinput"
@@ -248,12 +249,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                     Context:        m.context,
                     FilesWithMatch: m.filesWith,
                     Hidden:         m.hidden,
+                    Shell:          shellquote.FromEnv(),
                 }
                 tool := m.tools[m.toolIdx]
                 m.final = actions.BuildSearchCommand(tool, opts)
                 if m.cfg != nil && m.prefKey != "" {
-                    m.cfg.SetPreference(m.prefKey, string(tool))
-                    _ = config.Save(m.cfg)
+                    _ = config.Update(m.cfg, func(c *config.Config) { c.SetPreference(m.prefKey, string(tool)) })
                 }
                 return m, tea.Quit
             }
@@ -282,13 +283,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                     Context:        m.context,
                     FilesWithMatch: m.filesWith,
                     Hidden:         m.hidden,
+                    Shell:          shellquote.FromEnv(),
                 }
                 tool := m.tools[m.toolIdx]
                 m.final = actions.BuildSearchCommand(tool, opts)
                 // Persist preference if config is available.
                 if m.cfg != nil && m.prefKey != "" {
-                    m.cfg.SetPreference(m.prefKey, string(tool))
-                    _ = config.Save(m.cfg)
+                    _ = config.Update(m.cfg, func(c *config.Config) { c.SetPreference(m.prefKey, string(tool)) })
                 }
                 return m, tea.Quit
             }
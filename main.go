@@ -1,32 +1,116 @@
 package main
 
 import (
+	_ "embed"
 	"flag"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/BlackOrder/complete-command/internal/config"
 	"github.com/BlackOrder/complete-command/internal/integration"
 	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/reverse"
 	"github.com/BlackOrder/complete-command/internal/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// minLineConfidence is the confidence a reading of --line needs to open its
+// action pre-filled.
+const minLineConfidence = 0.3
+
+// defaultRegistry is the built-in action registry. Overlays from the
+// registry search path are merged over it at startup.
+//
+//go:embed registry.yaml
+var defaultRegistry []byte
+
+// loadConfig loads the user configuration. A configuration that cannot be
+// read is reported on stderr and replaced by an empty one, which is not
+// written back over the broken file.
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: config:", err)
+	}
+	return cfg
+}
+
+// useProfile selects the profile named by the --profile flag, by
+// $COMPLETE_COMMAND_PROFILE or, failing both, by the configuration. An
+// unusable profile that was asked for is fatal; an unusable saved one is
+// reported and the base used instead.
+func useProfile(cfg *config.Config, name string) {
+	if name == "" {
+		name = os.Getenv(config.ProfileEnv)
+	}
+	if name != "" {
+		if err := cfg.Use(name); err != nil {
+			fmt.Fprintln(os.Stderr, "error: config:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := cfg.Use(cfg.Active()); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: config:", err)
+		_ = cfg.Use(config.Base)
+	}
+}
+
+// loadRegistry loads the built-in registry merged with all overlays. Broken
+// overlays are reported on stderr and skipped.
+func loadRegistry() *registry.Registry {
+	reg, err := registry.LoadLayered(defaultRegistry)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: registry:", err)
+	}
+	return reg
+}
+
 // main runs the TUI search helper and prints the resulting command.
 func main() {
+	// Subcommands are dispatched before the interactive flags are parsed.
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		cfg := loadConfig()
+		useProfile(cfg, "")
+		reg := loadRegistry()
+		if reg == nil {
+			os.Exit(1)
+		}
+		os.Exit(runRender(os.Args[2:], reg, cfg, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "parse" {
+		reg := loadRegistry()
+		if reg == nil {
+			os.Exit(1)
+		}
+		os.Exit(runParse(os.Args[2:], reg, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		reg := loadRegistry()
+		if reg == nil {
+			os.Exit(1)
+		}
+		os.Exit(runExplain(os.Args[2:], reg, os.Stdout, os.Stderr))
+	}
+
 	// Define command-line flags for shell integration and action selection.
 	installShell := flag.Bool("install-shell", false, "Install shell integration (binds Ctrl+G to insert built commands)")
 	uninstallShell := flag.Bool("uninstall-shell", false, "Uninstall shell integration")
 	actionFlag := flag.String("action", "", "Skip the palette and start with the specified action (by ID, title or synonym)")
+	lineFlag := flag.String("line", "", "Prefill the form from a command line, such as the shell's current input")
+	profileFlag := flag.String("profile", "", "Use the named config profile (default: $"+config.ProfileEnv+" or the last one chosen)")
 
 	// Custom usage message describing the tool.
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "complete-command is an interactive helper for composing system and networking commands.\n\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [options] [action]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [options] [action]\n  %s render <action> [--tool name] [--profile name] [--set key=value]... [--json]\n  %s parse '<command>' [--action name] [--tool name] [--all]\n  %s explain '<command>' [--action name] [--json]\n  %s validate [file.yaml]...\n\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(flag.CommandLine.Output(), "\nIf an action is provided as a positional argument or via --action, the palette step is skipped and the corresponding form is shown immediately.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "With --line, a command the registry recognises with enough confidence opens its action with the tool and values read from it; otherwise the palette is shown.\n")
 	}
 	flag.Parse()
 
@@ -58,12 +142,13 @@ func main() {
 		}
 	}
 
-	// Load user configuration; ignore error on load.
-	cfg, _ := config.Load()
+	// Load user configuration; problems are reported but not fatal.
+	cfg := loadConfig()
+	useProfile(cfg, *profileFlag)
 
-	// Load registry of actions from YAML. If fail, fallback to legacy search.
-	reg, err := registry.Load("registry.yaml")
-	if err != nil || reg == nil || len(reg.Actions) == 0 {
+	// Load the layered registry of actions. If it is unusable, fallback to legacy search.
+	reg := loadRegistry()
+	if reg == nil || len(reg.Actions) == 0 {
 		// fallback search mode if registry unavailable.
 		m := ui.NewSearchModelWithConfig("search", cfg)
 		p := tea.NewProgram(m, tea.WithAltScreen())
@@ -83,47 +168,55 @@ func main() {
 	}
 
 	// If an action is specified, attempt to locate it in the registry.
+	var selected *registry.Action
 	if *actionFlag != "" {
-		name := strings.ToLower(*actionFlag)
-		var selected *registry.Action
 		// Match by ID, title, or synonym.
-		for i := range reg.Actions {
-			act := &reg.Actions[i]
-			if strings.ToLower(act.ID) == name || strings.ToLower(act.Title) == name {
-				selected = act
-				break
-			}
-			for _, syn := range act.Synonyms {
-				if strings.ToLower(syn) == name {
-					selected = act
-					break
-				}
-			}
-			if selected != nil {
-				break
-			}
+		selected = reg.Find(*actionFlag)
+		if selected == nil {
+			// If action not found, report error and exit.
+			fmt.Fprintf(os.Stderr, "Unknown action: %s\n", *actionFlag)
+			os.Exit(1)
 		}
+	}
+
+	// Read the given command line back into an action and its values,
+	// limited to the specified action if any.
+	var match *reverse.Match
+	if *lineFlag != "" {
+		var ms []reverse.Match
 		if selected != nil {
-			// Run the chosen action directly.
-			actModel := ui.NewActionModel(*selected, cfg)
-			p := tea.NewProgram(actModel, tea.WithAltScreen())
-			if mm, err2 := p.Run(); err2 != nil {
-				fmt.Fprintln(os.Stderr, "error:", err2)
-				os.Exit(1)
-			} else {
-				if out, ok := mm.(interface{ FinalCommand() string }); ok {
-					cmd := out.FinalCommand()
-					if cmd != "" {
-						fmt.Println(cmd)
-						os.Exit(0)
-					}
+			ms = reverse.ParseAction(selected, *lineFlag)
+		} else {
+			ms = reverse.Parse(reg, *lineFlag)
+		}
+		// A doubtful reading, such as a bare "rg" without the query,
+		// leaves the choice to the user.
+		if len(ms) > 0 && ms[0].Confidence() >= minLineConfidence {
+			match = &ms[0]
+			selected = match.Action
+		}
+	}
+
+	if selected != nil {
+		// Run the chosen action directly.
+		actModel := ui.NewActionModel(*selected, cfg)
+		if match != nil {
+			actModel = ui.NewPrefilledActionModel(*selected, cfg, match.Tool, match.Values)
+		}
+		p := tea.NewProgram(actModel, tea.WithAltScreen())
+		if mm, err2 := p.Run(); err2 != nil {
+			fmt.Fprintln(os.Stderr, "error:", err2)
+			os.Exit(1)
+		} else {
+			if out, ok := mm.(interface{ FinalCommand() string }); ok {
+				cmd := out.FinalCommand()
+				if cmd != "" {
+					fmt.Println(cmd)
+					os.Exit(0)
 				}
 			}
-			return
 		}
-		// If action not found, report error and exit.
-		fmt.Fprintf(os.Stderr, "Unknown action: %s\n", *actionFlag)
-		os.Exit(1)
+		return
 	}
 
 	// No specific action provided: show palette for selection.
@@ -138,6 +231,10 @@ func main() {
 			sel := pm.GetSelected()
 			if sel != nil {
 				actModel := ui.NewActionModel(*sel, cfg)
+				// Entries chosen from the history reopen pre-filled.
+				if e := pm.GetHistoryEntry(); e != nil {
+					actModel = ui.NewPrefilledActionModel(*sel, cfg, e.Tool, e.Values)
+				}
 				p2 := tea.NewProgram(actModel, tea.WithAltScreen())
 				if mm2, err3 := p2.Run(); err3 != nil {
 					fmt.Fprintln(os.Stderr, "error:", err3)
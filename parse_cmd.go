@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/reverse"
+)
+
+// parseResult is one reading of a command printed by the parse subcommand.
+type parseResult struct {
+	Action     string                 `json:"action"`
+	Tool       string                 `json:"tool"`
+	Confidence float64                `json:"confidence"`
+	Exact      bool                   `json:"exact"`
+	Values     map[string]interface{} `json:"values"`
+	Skipped    []string               `json:"skipped,omitempty"`
+}
+
+// runParse implements "complete-command parse '<cmd>' [flags]". It reads the
+// command back into the registry action, tool and field values that would
+// build it and prints the best reading, or every reading with --all, as
+// JSON. It returns a non-zero exit code when no action matches.
+func runParse(args []string, reg *registry.Registry, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	action := fs.String("action", "", "Only consider this action (by ID, title or synonym)")
+	tool := fs.String("tool", "", "Only consider this tool")
+	all := fs.Bool("all", false, "Print every reading, best first, instead of the best one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s parse '<command>' [--action name] [--tool name] [--all]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	// Allow the command to appear before or after the flags.
+	var line string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		line, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if line == "" {
+		line = strings.Join(fs.Args(), " ")
+	}
+	if strings.TrimSpace(line) == "" {
+		fs.Usage()
+		return 2
+	}
+
+	var ms []reverse.Match
+	if *action != "" {
+		act := reg.Find(*action)
+		if act == nil {
+			fmt.Fprintf(stderr, "Unknown action: %s\n", *action)
+			return 1
+		}
+		ms = reverse.ParseAction(act, line)
+	} else {
+		ms = reverse.Parse(reg, line)
+	}
+	results := []parseResult{}
+	for _, m := range ms {
+		if *tool != "" && m.Tool != *tool {
+			continue
+		}
+		results = append(results, parseResult{
+			Action:     m.Action.ID,
+			Tool:       m.Tool,
+			Confidence: m.Confidence(),
+			Exact:      m.Exact,
+			Values:     m.Values,
+			Skipped:    m.Skipped,
+		})
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(stderr, "No action matches: %s\n", line)
+		return 1
+	}
+	if *all {
+		writeJSON(stdout, results)
+	} else {
+		writeJSON(stdout, results[0])
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BlackOrder/complete-command/internal/config"
+	"github.com/BlackOrder/complete-command/internal/registry"
+	"github.com/BlackOrder/complete-command/internal/render"
+	"github.com/BlackOrder/complete-command/internal/shellquote"
+)
+
+// multiFlag collects repeated string flags such as --set.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+// renderResult is the JSON document printed by the render subcommand.
+type renderResult struct {
+	Action  string            `json:"action"`
+	Tool    string            `json:"tool"`
+	Command string            `json:"command,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// runRender implements "complete-command render <action> [flags]". It
+// resolves the action like --action does, applies field defaults and the
+// --set assignments, validates them and prints the rendered command. On
+// invalid input it prints a JSON document listing the errors and returns a
+// non-zero exit code.
+func runRender(args []string, reg *registry.Registry, cfg *config.Config, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	tool := fs.String("tool", "", "Tool to render for (default: preferred or first installed candidate)")
+	shell := fs.String("shell", "", "Shell to quote values for: sh, bash, zsh or fish (default: $SHELL)")
+	asJSON := fs.Bool("json", false, "Print a JSON document instead of the bare command")
+	profile := fs.String("profile", "", "Use the named config profile for the preferred tool")
+	var sets multiFlag
+	fs.Var(&sets, "set", "Set a field value as key=value (repeat for several fields or multi entries)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s render <action> [--tool name] [--profile name] [--set key=value]... [--json]\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	// Allow the action to appear before or after the flags.
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fs.Usage()
+		return 2
+	}
+	if *profile != "" {
+		if err := cfg.Use(*profile); err != nil {
+			fmt.Fprintf(stderr, "config: %v\n", err)
+			return 1
+		}
+	}
+	act := reg.Find(name)
+	if act == nil {
+		fmt.Fprintf(stderr, "Unknown action: %s\n", name)
+		return 1
+	}
+
+	res := renderResult{Action: act.ID, Errors: make(map[string]string)}
+	if *tool != "" {
+		if _, ok := act.Template[*tool]; !ok {
+			res.Errors["tool"] = fmt.Sprintf("unknown tool %q; expected one of %s", *tool, strings.Join(act.Candidates, ", "))
+		}
+		res.Tool = *tool
+	} else if tools := render.Tools(*act, cfg); len(tools) > 0 {
+		res.Tool = tools[0]
+	}
+	values := render.Defaults(*act)
+	seen := make(map[string]bool)
+	for _, s := range sets {
+		key, val, err := render.ParseAssignment(s)
+		if err != nil {
+			res.Errors[s] = err.Error()
+			continue
+		}
+		if err := render.Set(*act, values, seen, key, val); err != nil {
+			res.Errors[key] = err.Error()
+		}
+	}
+	if len(res.Errors) == 0 {
+		for k, msg := range act.Validate(res.Tool, values) {
+			res.Errors[k] = msg
+		}
+	}
+	if len(res.Errors) > 0 {
+		writeJSON(stdout, res)
+		return 1
+	}
+
+	sh := shellquote.FromEnv()
+	if *shell != "" {
+		sh = shellquote.Detect(*shell)
+	}
+	cmd, err := render.Command(*act, res.Tool, values, sh)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", act.ID, err)
+		return 1
+	}
+	res.Command = cmd
+	if *asJSON {
+		writeJSON(stdout, res)
+	} else {
+		fmt.Fprintln(stdout, res.Command)
+	}
+	return 0
+}
+
+// writeJSON prints v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BlackOrder/complete-command/internal/registry"
+)
+
+// runValidate implements "complete-command validate [files]". Without
+// arguments it checks the built-in registry and every overlay on the
+// registry search path. With arguments it checks the given files, in order,
+// layered over the built-in registry. Every problem is printed as
+// file:line:column: message and the exit code is non-zero if any was found.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s validate [file.yaml]...\n\nWithout files, the built-in registry and all overlays on the search path are checked.\n", os.Args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var problems []registry.Problem
+	files := fs.Args()
+	if len(files) == 0 {
+		v := registry.NewValidator(nil)
+		problems = append(problems, v.Validate("registry.yaml (built-in)", defaultRegistry)...)
+		files = registry.SearchPath()
+		problems = append(problems, validateFiles(v, files)...)
+	} else {
+		// Layer the given files over the built-in registry only.
+		base, err := registry.Parse(bytes.NewReader(defaultRegistry))
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return 1
+		}
+		problems = append(problems, validateFiles(registry.NewValidator(base), files)...)
+	}
+	for _, p := range problems {
+		fmt.Fprintln(stdout, p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(stderr, "%d problem(s) found\n", len(problems))
+		return 1
+	}
+	return 0
+}
+
+// validateFiles reads and validates each file in turn.
+func validateFiles(v *registry.Validator, files []string) []registry.Problem {
+	var problems []registry.Problem
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			problems = append(problems, registry.Problem{File: f, Msg: err.Error()})
+			continue
+		}
+		problems = append(problems, v.Validate(f, data)...)
+	}
+	return problems
+}